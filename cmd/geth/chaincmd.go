@@ -215,6 +215,28 @@ Use "ethereum dump 0" to dump the genesis block.`,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 	}
+	verifyStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyState),
+		Name:      "verify-state",
+		Usage:     "Verify the integrity of the trie nodes and codes of a state",
+		ArgsUsage: "[<root>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.LegacyTestnetFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-state command iterates the account trie and all storage tries of the
+given state root (or the head state if none given), checking every node and code
+stored on disk against its hash to detect silent corruption. An interrupted run
+resumes where it left off when restarted for the same root.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -446,7 +468,7 @@ func copyDb(ctx *cli.Context) error {
 
 	var syncBloom *trie.SyncBloom
 	if syncMode == downloader.FastSync {
-		syncBloom = trie.NewSyncBloom(uint64(ctx.GlobalInt(utils.CacheFlag.Name)/2), chainDb)
+		syncBloom = trie.NewSyncBloom(uint64(ctx.GlobalInt(utils.CacheFlag.Name)/2), chainDb, nil)
 	}
 	dl := downloader.New(0, chainDb, syncBloom, new(event.TypeMux), chain, nil, nil)
 
@@ -594,6 +616,23 @@ func inspect(ctx *cli.Context) error {
 	return rawdb.InspectDatabase(chainDb)
 }
 
+func verifyState(ctx *cli.Context) error {
+	node, _ := makeConfigNode(ctx)
+	defer node.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, node, true)
+	defer chainDb.Close()
+
+	root := chain.CurrentBlock().Root()
+	if ctx.NArg() > 0 {
+		if len(common.FromHex(ctx.Args().First())) != common.HashLength {
+			utils.Fatalf("Invalid state root: %s", ctx.Args().First())
+		}
+		root = common.HexToHash(ctx.Args().First())
+	}
+	return state.VerifyState(chainDb, root)
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -107,7 +107,15 @@ var (
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheTrieFlag,
+		utils.CacheTrieJournalFlag,
+		utils.CacheTrieRejournalFlag,
+		utils.CacheTrieVerifyFlag,
+		utils.CacheTrieWarmAccountsFlag,
+		utils.CacheTrieWarmFlag,
+		utils.CacheTrieWarmDepthFlag,
+		utils.CacheTrieWarmBudgetFlag,
 		utils.CacheGCFlag,
+		utils.CacheMemoryLimitFlag,
 		utils.CacheSnapshotFlag,
 		utils.CacheNoPrefetchFlag,
 		utils.ListenPortFlag,
@@ -224,6 +232,7 @@ func init() {
 		dumpCommand,
 		dumpGenesisCommand,
 		inspectCommand,
+		verifyStateCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
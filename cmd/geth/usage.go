@@ -139,7 +139,15 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.CacheFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheTrieFlag,
+			utils.CacheTrieJournalFlag,
+			utils.CacheTrieRejournalFlag,
+			utils.CacheTrieVerifyFlag,
+			utils.CacheTrieWarmAccountsFlag,
+			utils.CacheTrieWarmFlag,
+			utils.CacheTrieWarmDepthFlag,
+			utils.CacheTrieWarmBudgetFlag,
 			utils.CacheGCFlag,
+			utils.CacheMemoryLimitFlag,
 			utils.CacheSnapshotFlag,
 			utils.CacheNoPrefetchFlag,
 		},
@@ -385,11 +385,49 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie caching (default = 15% full mode, 30% archive mode)",
 		Value: 15,
 	}
+	CacheTrieJournalFlag = cli.StringFlag{
+		Name:  "cache.trie.journal",
+		Usage: "Disk journal directory for trie cache to survive node restarts (empty to disable)",
+		Value: eth.DefaultConfig.TrieCleanCacheJournal,
+	}
+	CacheTrieRejournalFlag = cli.DurationFlag{
+		Name:  "cache.trie.rejournal",
+		Usage: "Time interval to regenerate the trie cache journal",
+		Value: eth.DefaultConfig.TrieCleanCacheRejournal,
+	}
+	CacheTrieVerifyFlag = cli.Uint64Flag{
+		Name:  "cache.trie.verify",
+		Usage: "Verify every n-th trie cache hit against its hash (0 = only spot-check journaled caches, 1 = always)",
+		Value: eth.DefaultConfig.TrieCleanCacheVerify,
+	}
+	CacheTrieWarmAccountsFlag = cli.IntFlag{
+		Name:  "cache.trie.warm.accounts",
+		Usage: "Number of top account trie levels to pre-load into the trie cache on startup (0 = disabled)",
+	}
+	CacheTrieWarmFlag = cli.StringFlag{
+		Name:  "cache.trie.warm",
+		Usage: "Comma separated contract addresses whose storage tries to pre-load into the trie cache on startup",
+	}
+	CacheTrieWarmDepthFlag = cli.IntFlag{
+		Name:  "cache.trie.warm.depth",
+		Usage: "Number of top storage trie levels to pre-load for each hot contract",
+		Value: eth.DefaultConfig.TrieWarmDepth,
+	}
+	CacheTrieWarmBudgetFlag = cli.IntFlag{
+		Name:  "cache.trie.warm.budget",
+		Usage: "Maximum number of trie nodes to pre-load into the trie cache on startup (0 = unlimited)",
+		Value: eth.DefaultConfig.TrieWarmBudget,
+	}
 	CacheGCFlag = cli.IntFlag{
 		Name:  "cache.gc",
 		Usage: "Percentage of cache memory allowance to use for trie pruning (default = 25% full mode, 0% archive mode)",
 		Value: 25,
 	}
+	CacheMemoryLimitFlag = cli.IntFlag{
+		Name:  "cache.memlimit",
+		Usage: "Process memory limit (MB) above which to shrink the trie caches (0 = disabled)",
+		Value: eth.DefaultConfig.TrieMemoryLimit,
+	}
 	CacheSnapshotFlag = cli.IntFlag{
 		Name:  "cache.snapshot",
 		Usage: "Percentage of cache memory allowance to use for snapshot caching (default = 10% full mode, 20% archive mode)",
@@ -1520,9 +1558,39 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheTrieJournalFlag.Name) {
+		cfg.TrieCleanCacheJournal = ctx.GlobalString(CacheTrieJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheTrieRejournalFlag.Name) {
+		cfg.TrieCleanCacheRejournal = ctx.GlobalDuration(CacheTrieRejournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheTrieVerifyFlag.Name) {
+		cfg.TrieCleanCacheVerify = ctx.GlobalUint64(CacheTrieVerifyFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheTrieWarmAccountsFlag.Name) {
+		cfg.TrieWarmAccounts = ctx.GlobalInt(CacheTrieWarmAccountsFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheTrieWarmFlag.Name) {
+		for _, account := range strings.Split(ctx.GlobalString(CacheTrieWarmFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid contract in --%s: %s", CacheTrieWarmFlag.Name, trimmed)
+			} else {
+				cfg.TrieWarmContracts = append(cfg.TrieWarmContracts, common.HexToAddress(trimmed))
+			}
+		}
+	}
+	if ctx.GlobalIsSet(CacheTrieWarmDepthFlag.Name) {
+		cfg.TrieWarmDepth = ctx.GlobalInt(CacheTrieWarmDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheTrieWarmBudgetFlag.Name) {
+		cfg.TrieWarmBudget = ctx.GlobalInt(CacheTrieWarmBudgetFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieDirtyCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheMemoryLimitFlag.Name) {
+		cfg.TrieMemoryLimit = ctx.GlobalInt(CacheMemoryLimitFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheSnapshotFlag.Name) {
 		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
 	}
@@ -23,6 +23,7 @@ import (
 	"io"
 	"math/big"
 	mrand "math/rand"
+	"runtime"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -89,6 +90,9 @@ const (
 	badBlockLimit       = 10
 	TriesInMemory       = 128
 
+	memoryCheckInterval = 3 * time.Second // Time interval to sample the memory usage at for trie cache scaling
+	trieCacheShrinkMax  = 3               // Maximum number of times to halve the trie caches under memory pressure
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
 	// Changelog:
@@ -115,12 +119,20 @@ const (
 // CacheConfig contains the configuration values for the trie caching/pruning
 // that's resident in a blockchain.
 type CacheConfig struct {
-	TrieCleanLimit      int           // Memory allowance (MB) to use for caching trie nodes in memory
-	TrieCleanNoPrefetch bool          // Whether to disable heuristic state prefetching for followup blocks
-	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
-	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
-	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
-	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
+	TrieCleanLimit      int              // Memory allowance (MB) to use for caching trie nodes in memory
+	TrieCleanJournal    string           // Disk journal for saving clean cache entries.
+	TrieCleanRejournal  time.Duration    // Time interval to dump clean cache to disk periodically
+	TrieCleanVerify     uint64           // Verify every n-th clean cache hit against its hash (0 = journaled caches only, 1 = always)
+	TrieCleanNoPrefetch bool             // Whether to disable heuristic state prefetching for followup blocks
+	TrieWarmAccounts    int              // Number of top account trie levels to pre-load into the clean cache on startup
+	TrieWarmContracts   []common.Address // Contracts whose storage tries to pre-load into the clean cache on startup
+	TrieWarmDepth       int              // Number of top storage trie levels to pre-load for each hot contract
+	TrieWarmBudget      int              // Maximum number of trie nodes to pre-load on startup (0 = unlimited)
+	TrieDirtyLimit      int              // Memory limit (MB) at which to start flushing dirty trie nodes to disk
+	TrieDirtyDisabled   bool             // Whether to disable trie write caching and GC altogether (archive node)
+	TrieTimeLimit       time.Duration    // Time limit after which to flush the current in-memory trie to disk
+	TrieMemoryLimit     int              // Process memory limit (MB) above which to shrink the trie caches (0 = disabled)
+	SnapshotLimit       int              // Memory allowance (MB) to use for caching snapshot entries in memory
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -148,6 +160,8 @@ type BlockChain struct {
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
+	trieDirtyLimit int32 // Current dirty trie memory limit (MB), shrunk under memory pressure (atomic access)
+
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
 	//  * 0:   means no limit and regenerate any missing indexes
@@ -162,6 +176,8 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	witnessFeed   event.Feed
+	witnessSubs   int32 // Number of live witness subscriptions (atomic access)
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -220,7 +236,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:    cacheConfig,
 		db:             db,
 		triegc:         prque.New(nil),
-		stateCache:     state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
+		trieDirtyLimit: int32(cacheConfig.TrieDirtyLimit),
+		stateCache:     state.NewDatabaseWithConfig(db, &trie.Config{Cache: cacheConfig.TrieCleanLimit, Journal: cacheConfig.TrieCleanJournal, Verify: cacheConfig.TrieCleanVerify}),
 		quit:           make(chan struct{}),
 		shouldPreserve: shouldPreserve,
 		bodyCache:      bodyCache,
@@ -328,9 +345,183 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		bc.txLookupLimit = *txLookupLimit
 		go bc.maintainTxIndex(txIndexBlock)
 	}
+	// If periodic cache journal is required, spin it up.
+	if bc.cacheConfig.TrieCleanRejournal > 0 && bc.cacheConfig.TrieCleanJournal != "" {
+		if bc.cacheConfig.TrieCleanRejournal < time.Minute {
+			log.Warn("Sanitizing invalid trie cache journal time", "provided", bc.cacheConfig.TrieCleanRejournal, "updated", time.Minute)
+			bc.cacheConfig.TrieCleanRejournal = time.Minute
+		}
+		triedb := bc.stateCache.TrieDB()
+		bc.wg.Add(1)
+		go func() {
+			defer bc.wg.Done()
+			triedb.SaveCachePeriodically(bc.cacheConfig.TrieCleanJournal, bc.cacheConfig.TrieCleanRejournal, bc.quit)
+		}()
+	}
+	// If clean cache warming is requested, pre-load the hot tries in the background
+	if bc.cacheConfig.TrieWarmAccounts > 0 || (len(bc.cacheConfig.TrieWarmContracts) > 0 && bc.cacheConfig.TrieWarmDepth > 0) {
+		bc.wg.Add(1)
+		go bc.warmTrieCache(bc.CurrentBlock().Root())
+	}
+	// If a memory limit is set, scale the trie caches with the memory pressure
+	if bc.cacheConfig.TrieMemoryLimit > 0 {
+		bc.wg.Add(1)
+		go bc.scaleTrieCaches()
+	}
 	return bc, nil
 }
 
+// warmTrieCache pre-loads the top levels of the account trie and of the
+// configured hot contracts' storage tries in the given state into the clean
+// trie cache, bounded by the configured warm-up budget. The account trie is
+// warmed first, the contracts share whatever budget remains.
+func (bc *BlockChain) warmTrieCache(root common.Hash) {
+	defer bc.wg.Done()
+
+	var (
+		start  = time.Now()
+		triedb = bc.stateCache.TrieDB()
+		budget = bc.cacheConfig.TrieWarmBudget
+		nodes  int
+	)
+	if depth := bc.cacheConfig.TrieWarmAccounts; depth > 0 {
+		nodes += triedb.Warm([]common.Hash{root}, depth, budget, bc.quit)
+	}
+	if len(bc.cacheConfig.TrieWarmContracts) > 0 && bc.cacheConfig.TrieWarmDepth > 0 && (budget == 0 || nodes < budget) {
+		statedb, err := state.New(root, bc.stateCache, nil)
+		if err != nil {
+			log.Warn("Failed to open state for trie cache warm-up", "root", root, "err", err)
+			return
+		}
+		var roots []common.Hash
+		for _, addr := range bc.cacheConfig.TrieWarmContracts {
+			if storage := statedb.StorageTrie(addr); storage != nil {
+				roots = append(roots, storage.Hash())
+			}
+		}
+		remaining := budget
+		if budget > 0 {
+			remaining = budget - nodes
+		}
+		nodes += triedb.Warm(roots, bc.cacheConfig.TrieWarmDepth, remaining, bc.quit)
+	}
+	log.Info("Warmed up trie clean cache", "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// trieCacheScaler tracks how far the trie caches need to be shrunk to keep the
+// process below its memory limit. The caches are halved whenever the memory use
+// crosses 90% of the limit and doubled back once it drops under 70%.
+type trieCacheScaler struct {
+	limit uint64 // Process memory limit in bytes
+	clean int    // Configured clean cache allowance (MB)
+	dirty int    // Configured dirty cache allowance (MB)
+	shift uint   // Number of times the configured allowances are currently halved
+}
+
+// sizes returns the current clean and dirty cache allowances in megabytes.
+// Enabled caches are never shrunk below a single megabyte.
+func (s *trieCacheScaler) sizes() (int, int) {
+	clean, dirty := s.clean>>s.shift, s.dirty>>s.shift
+	if clean == 0 && s.clean > 0 {
+		clean = 1
+	}
+	if dirty == 0 && s.dirty > 0 {
+		dirty = 1
+	}
+	return clean, dirty
+}
+
+// update adjusts the cache allowances to the given memory use in bytes. It
+// returns whether the caches were shrunk (-1), grown (1) or left alone (0).
+func (s *trieCacheScaler) update(used uint64) int {
+	high, low := s.limit/10*9, s.limit/10*7
+
+	switch {
+	case used > high && s.shift < trieCacheShrinkMax:
+		s.shift++
+		return -1
+
+	case used < low && s.shift > 0:
+		// Doubling the caches adds their current size on top, only do that if it
+		// doesn't push the process right back over the limit
+		clean, dirty := s.sizes()
+		if used+uint64(clean+dirty)*1024*1024 < high {
+			s.shift--
+			return 1
+		}
+	}
+	return 0
+}
+
+// scaleTrieCaches periodically samples the memory use of the process, shrinking
+// the clean and dirty trie caches as it approaches the configured limit and
+// growing them back once the pressure subsides.
+//
+// The dirty cache lives on the Go heap and is released by the next flush after
+// a shrink. The clean cache lives off-heap, where fastcache pools the chunks of
+// a released cache for reuse instead of returning them to the OS, so shrinking
+// it caps its footprint rather than reducing the resident size.
+func (bc *BlockChain) scaleTrieCaches() {
+	defer bc.wg.Done()
+
+	var (
+		triedb = bc.stateCache.TrieDB()
+		scaler = &trieCacheScaler{
+			limit: uint64(bc.cacheConfig.TrieMemoryLimit) * 1024 * 1024,
+			clean: bc.cacheConfig.TrieCleanLimit,
+			dirty: bc.cacheConfig.TrieDirtyLimit,
+		}
+		ticker = time.NewTicker(memoryCheckInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The off-heap clean cache isn't part of the runtime stats, count it
+			// at its full allowance
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+
+			clean, _ := scaler.sizes()
+			used := stats.Sys - stats.HeapReleased + uint64(clean)*1024*1024
+
+			change := scaler.update(used)
+			if change == 0 {
+				continue
+			}
+			clean, dirty := scaler.sizes()
+			atomic.StoreInt32(&bc.trieDirtyLimit, int32(dirty))
+
+			// When shrinking, release the old clean cache right away instead of
+			// refilling the new one from it
+			if clean > 0 {
+				var err error
+				if change < 0 {
+					err = triedb.ResetCleanCache(clean)
+				} else {
+					err = triedb.ResizeCleanCache(clean)
+				}
+				if err != nil {
+					log.Error("Failed to scale trie clean cache", "err", err)
+				}
+			}
+			context := []interface{}{
+				"used", common.StorageSize(used), "limit", common.StorageSize(scaler.limit),
+				"clean", common.StorageSize(clean) * 1024 * 1024, "dirty", common.StorageSize(dirty) * 1024 * 1024,
+			}
+			if change < 0 {
+				log.Warn("Shrunk trie caches under memory pressure", context...)
+			} else {
+				log.Info("Grew trie caches after memory pressure", context...)
+			}
+
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
 // GetVMConfig returns the block chain VM config.
 func (bc *BlockChain) GetVMConfig() *vm.Config {
 	return &bc.vmConfig
@@ -919,6 +1110,12 @@ func (bc *BlockChain) Stop() {
 			log.Error("Dangling trie nodes after full cleanup")
 		}
 	}
+	// Ensure all live cached entries be saved into disk, so that we can skip
+	// cache warmup when node restarts.
+	if bc.cacheConfig.TrieCleanJournal != "" {
+		triedb := bc.stateCache.TrieDB()
+		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
+	}
 	log.Info("Blockchain stopped")
 }
 
@@ -1454,7 +1651,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			// If we exceeded our memory allowance, flush matured singleton nodes to disk
 			var (
 				nodes, imgs = triedb.Size()
-				limit       = common.StorageSize(bc.cacheConfig.TrieDirtyLimit) * 1024 * 1024
+				limit       = common.StorageSize(atomic.LoadInt32(&bc.trieDirtyLimit)) * 1024 * 1024
 			)
 			if nodes > limit || imgs > 4*1024*1024 {
 				triedb.Cap(limit - ethdb.IdealBatchSize)
@@ -1832,7 +2029,6 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		if err != nil {
 			return it.index, err
 		}
-
 		// Update the metrics touched during block commit
 		accountCommitTimer.Update(statedb.AccountCommits)   // Account commits are complete, we can mark them
 		storageCommitTimer.Update(statedb.StorageCommits)   // Storage commits are complete, we can mark them
@@ -1841,6 +2037,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		blockWriteTimer.Update(time.Since(substart) - statedb.AccountCommits - statedb.StorageCommits - statedb.SnapshotCommits)
 		blockInsertTimer.UpdateSince(start)
 
+		// Feed the block's witness to any external subscribers. Recording it
+		// re-executes the block, so keep it out of the import metrics.
+		if atomic.LoadInt32(&bc.witnessSubs) > 0 {
+			bc.postWitness(block)
+		}
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", block.Number(), "hash", block.Hash(),
@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -35,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -3028,3 +3030,185 @@ func TestInitThenFailCreateContract(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a chain with the trie cache journal disabled never writes into or
+// removes anything from the working directory, neither periodically nor on
+// shutdown.
+func TestTrieCleanJournalDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trie-journal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "sentinel"), []byte{0x01}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = new(Genesis).MustCommit(db)
+		config  = &CacheConfig{
+			TrieCleanLimit:     256,
+			TrieCleanRejournal: time.Minute,
+			TrieDirtyLimit:     256,
+			TrieTimeLimit:      5 * time.Minute,
+		}
+	)
+	chain, err := NewBlockChain(db, config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 4, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "sentinel" {
+		t.Fatalf("working directory modified: %d entries", len(files))
+	}
+}
+
+// readRecorder is a key-value store tracking all the keys read from it.
+type readRecorder struct {
+	ethdb.KeyValueStore
+
+	reads map[string]struct{}
+	lock  sync.Mutex
+}
+
+func (r *readRecorder) Get(key []byte) ([]byte, error) {
+	r.lock.Lock()
+	r.reads[string(key)] = struct{}{}
+	r.lock.Unlock()
+	return r.KeyValueStore.Get(key)
+}
+
+// Tests that the storage tries of the configured hot contracts are pre-loaded
+// into the clean cache when the chain is started.
+func TestTrieCacheWarmup(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		storage  = make(map[common.Hash]common.Hash)
+	)
+	for i := 1; i <= 256; i++ {
+		storage[common.BigToHash(big.NewInt(int64(i)))] = common.BigToHash(big.NewInt(int64(i)))
+	}
+	alloc := GenesisAlloc{contract: {Balance: big.NewInt(1), Code: []byte{0x00}, Storage: storage}}
+
+	base := testTrieCacheWarmup(t, alloc, func(config *CacheConfig) {})
+	nodes := testTrieCacheWarmup(t, alloc, func(config *CacheConfig) {
+		config.TrieWarmContracts = []common.Address{contract}
+		config.TrieWarmDepth = 2
+	})
+	if nodes < base+17 {
+		t.Errorf("storage trie not warmed: have %d node reads, want at least %d", nodes, base+17)
+	}
+}
+
+// Tests that the top levels of the head account trie are pre-loaded into the
+// clean cache when the chain is started, bounded by the warm-up budget.
+func TestTrieCacheWarmupAccounts(t *testing.T) {
+	alloc := make(GenesisAlloc)
+	for i := 1; i <= 256; i++ {
+		alloc[common.BigToAddress(big.NewInt(int64(i)))] = GenesisAccount{Balance: big.NewInt(1)}
+	}
+	base := testTrieCacheWarmup(t, alloc, func(config *CacheConfig) {})
+	if nodes := testTrieCacheWarmup(t, alloc, func(config *CacheConfig) {
+		config.TrieWarmAccounts = 2
+	}); nodes < base+16 {
+		t.Errorf("account trie not warmed: have %d node reads, want at least %d", nodes, base+16)
+	}
+	if nodes := testTrieCacheWarmup(t, alloc, func(config *CacheConfig) {
+		config.TrieWarmAccounts = 2
+		config.TrieWarmBudget = 4
+	}); nodes > base+4 {
+		t.Errorf("warm-up budget exceeded: have %d node reads, want at most %d", nodes, base+4)
+	}
+}
+
+// testTrieCacheWarmup starts a chain on top of the given genesis allocation with
+// the warm-up settings applied by override, returning the number of trie nodes
+// read from disk while starting up.
+func testTrieCacheWarmup(t *testing.T, alloc GenesisAlloc, override func(config *CacheConfig)) int {
+	var (
+		recorder = &readRecorder{KeyValueStore: memorydb.New(), reads: make(map[string]struct{})}
+		db       = rawdb.NewDatabase(recorder)
+	)
+	(&Genesis{Config: params.TestChainConfig, Alloc: alloc}).MustCommit(db)
+
+	recorder.lock.Lock()
+	recorder.reads = make(map[string]struct{})
+	recorder.lock.Unlock()
+
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+	}
+	override(config)
+
+	chain, err := NewBlockChain(db, config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	chain.wg.Wait() // Wait for the warm-up to finish
+	chain.Stop()
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	var nodes int
+	for key := range recorder.reads {
+		if len(key) == common.HashLength {
+			nodes++
+		}
+	}
+	return nodes
+}
+
+// Tests that the trie caches are halved as the memory use crosses the high
+// watermark, and only grown back once it drops far enough below the limit.
+func TestTrieCacheScaler(t *testing.T) {
+	scaler := &trieCacheScaler{limit: 1000 * 1024 * 1024, clean: 512, dirty: 12}
+
+	tests := []struct {
+		used   uint64 // Memory use reported, in megabytes
+		change int
+		clean  int
+		dirty  int
+	}{
+		{used: 800, change: 0, clean: 512, dirty: 12}, // Between the watermarks
+		{used: 950, change: -1, clean: 256, dirty: 6}, // Above the high watermark
+		{used: 950, change: -1, clean: 128, dirty: 3}, // Still above, shrink again
+		{used: 950, change: -1, clean: 64, dirty: 1},  // Dirty cache kept at a megabyte
+		{used: 950, change: 0, clean: 64, dirty: 1},   // Shrunk as far as allowed
+		{used: 800, change: 0, clean: 64, dirty: 1},   // Between the watermarks
+		{used: 600, change: 1, clean: 128, dirty: 3},  // Below the low watermark
+		{used: 600, change: 1, clean: 256, dirty: 6},  // Still below, grow again
+		{used: 680, change: 0, clean: 256, dirty: 6},  // Growing would cross the high watermark
+		{used: 100, change: 1, clean: 512, dirty: 12}, // Back to the configured sizes
+		{used: 100, change: 0, clean: 512, dirty: 12}, // Never grown beyond the configured sizes
+	}
+	for i, tt := range tests {
+		if change := scaler.update(tt.used * 1024 * 1024); change != tt.change {
+			t.Errorf("test %d: change mismatch: have %d, want %d", i, change, tt.change)
+		}
+		if clean, dirty := scaler.sizes(); clean != tt.clean || dirty != tt.dirty {
+			t.Errorf("test %d: size mismatch: have %d/%d, want %d/%d", i, clean, dirty, tt.clean, tt.dirty)
+		}
+	}
+}
@@ -41,3 +41,11 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// WitnessEvent is posted for every imported block while there are witness
+// subscribers, carrying the state the block read and the state it wrote.
+type WitnessEvent struct {
+	Block   *types.Block
+	Witness *Witness // Headers and pre-state trie nodes and codes read during execution
+	Written [][]byte // Post-state trie nodes and codes written at commit, sorted by hash and deduplicated
+}
@@ -52,6 +52,47 @@ func WriteDatabaseVersion(db ethdb.KeyValueWriter, version uint64) {
 	}
 }
 
+// ReadDatabaseID retrieves the unique identifier of the database.
+func ReadDatabaseID(db ethdb.KeyValueReader) []byte {
+	id, _ := db.Get(databaseIDKey)
+	if len(id) == 0 {
+		return nil
+	}
+	return id
+}
+
+// WriteDatabaseID stores the unique identifier of the database.
+func WriteDatabaseID(db ethdb.KeyValueWriter, id []byte) {
+	if err := db.Put(databaseIDKey, id); err != nil {
+		log.Crit("Failed to store the database id", "err", err)
+	}
+}
+
+// ReadStateVerifyProgress retrieves the state root and the hash of the last
+// account verified by an interrupted state verification, if any.
+func ReadStateVerifyProgress(db ethdb.KeyValueReader) (common.Hash, []byte) {
+	data, _ := db.Get(stateVerifyProgressKey)
+	if len(data) < common.HashLength {
+		return common.Hash{}, nil
+	}
+	return common.BytesToHash(data[:common.HashLength]), data[common.HashLength:]
+}
+
+// WriteStateVerifyProgress stores the state root and the hash of the last account
+// verified by a state verification, to allow resuming it.
+func WriteStateVerifyProgress(db ethdb.KeyValueWriter, root common.Hash, marker []byte) {
+	if err := db.Put(stateVerifyProgressKey, append(root.Bytes(), marker...)); err != nil {
+		log.Crit("Failed to store state verification progress", "err", err)
+	}
+}
+
+// DeleteStateVerifyProgress deletes the progress of a state verification.
+func DeleteStateVerifyProgress(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateVerifyProgressKey); err != nil {
+		log.Crit("Failed to remove state verification progress", "err", err)
+	}
+}
+
 // ReadChainConfig retrieves the consensus settings based on the given genesis hash.
 func ReadChainConfig(db ethdb.KeyValueReader, hash common.Hash) *params.ChainConfig {
 	data, _ := db.Get(configKey(hash))
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"os"
@@ -171,6 +172,12 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 			// feezer.
 		}
 	}
+	// The genesis checks above can't tell apart two databases of the same network,
+	// so cross validate the unique identifiers the key-value store and the freezer
+	// were stamped with on creation.
+	if err := validateDatabaseID(db, frdb); err != nil {
+		return nil, err
+	}
 	// Freezer is consistent with the key-value database, permit combining the two
 	go frdb.freeze(db)
 
@@ -180,6 +187,81 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 	}, nil
 }
 
+// validateDatabaseID ensures the key-value store and the freezer originate from
+// the same database, stamping both of them with a fresh random identifier if
+// neither was tagged yet (new database or one predating identifiers).
+func validateDatabaseID(db ethdb.KeyValueStore, frdb *freezer) error {
+	frid, err := frdb.readID()
+	if err != nil {
+		return err
+	}
+	kvid := ReadDatabaseID(db)
+
+	switch {
+	case kvid != nil && frid != nil:
+		if !bytes.Equal(kvid, frid) {
+			return fmt.Errorf("database id mismatch: %#x (leveldb) != %#x (ancients)", kvid, frid)
+		}
+		return nil
+
+	case kvid == nil && frid == nil:
+		// Neither store is tagged. Tag the freezer first and the key-value store
+		// only afterwards, tracking the identifier as pending until both succeed.
+		// Reuse a pending one from an interrupted attempt to keep retries stable.
+		id, _ := db.Get(databaseIDPendingKey)
+		if len(id) == 0 {
+			id = make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return err
+			}
+			if err := db.Put(databaseIDPendingKey, id); err != nil {
+				return err
+			}
+		}
+		if err := frdb.writeID(id); err != nil {
+			return fmt.Errorf("failed to stamp ancients with database id: %v", err)
+		}
+		log.Info("Stamped database with unique identifier", "id", fmt.Sprintf("%x", id))
+		return commitDatabaseID(db, id)
+
+	case kvid == nil:
+		// The freezer is tagged but the key-value store isn't. If the freezer was
+		// tagged with our pending identifier, a previous stamping was interrupted
+		// before completing, so finish it.
+		if pending, _ := db.Get(databaseIDPendingKey); bytes.Equal(pending, frid) {
+			return commitDatabaseID(db, frid)
+		}
+		// Otherwise this is fine if the state was wiped and reinited from an
+		// existing freezer, but a populated legacy key-value store doesn't belong
+		// to this freezer.
+		if genesis, _ := db.Get(headerHashKey(0)); len(genesis) > 0 {
+			return fmt.Errorf("database id mismatch: untagged leveldb != %#x (ancients)", frid)
+		}
+		WriteDatabaseID(db, frid)
+		return nil
+
+	default:
+		// The key-value store is tagged but the freezer isn't. This is fine if the
+		// freezer is brand new, but a populated legacy freezer doesn't belong to
+		// this key-value store.
+		if frozen, _ := frdb.Ancients(); frozen > 0 {
+			return fmt.Errorf("database id mismatch: %#x (leveldb) != untagged ancients", kvid)
+		}
+		return frdb.writeID(kvid)
+	}
+}
+
+// commitDatabaseID stores the unique identifier into the key-value store after
+// the freezer was successfully tagged with it, dropping the pending marker.
+func commitDatabaseID(db ethdb.KeyValueStore, id []byte) error {
+	batch := db.NewBatch()
+	WriteDatabaseID(batch, id)
+	if err := batch.Delete(databaseIDPendingKey); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
 // NewMemoryDatabase creates an ephemeral in-memory key-value database without a
 // freezer moving immutable chain segments into cold storage.
 func NewMemoryDatabase() ethdb.Database {
@@ -300,7 +382,7 @@ func InspectDatabase(db ethdb.Database) error {
 			trieSize += size
 		default:
 			var accounted bool
-			for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey} {
+			for _, meta := range [][]byte{databaseVerisionKey, databaseIDKey, databaseIDPendingKey, stateVerifyProgressKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey} {
 				if bytes.Equal(key, meta) {
 					metadata += size
 					accounted = true
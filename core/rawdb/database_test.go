@@ -0,0 +1,142 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// Tests that key-value stores and freezers are stamped with a shared unique
+// identifier on creation, and that mixing them across databases is rejected.
+func TestDatabaseIDMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdb-id-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		kvA, frA = filepath.Join(dir, "a", "chaindata"), filepath.Join(dir, "a", "ancient")
+		kvB, frB = filepath.Join(dir, "b", "chaindata"), filepath.Join(dir, "b", "ancient")
+	)
+	for _, paths := range [][2]string{{kvA, frA}, {kvB, frB}} {
+		db, err := NewLevelDBDatabaseWithFreezer(paths[0], 16, 16, paths[1], "")
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		if ReadDatabaseID(db) == nil {
+			t.Fatalf("database not stamped with an id")
+		}
+		db.Close()
+	}
+	// Reopening a consistent combination should succeed
+	db, err := NewLevelDBDatabaseWithFreezer(kvA, 16, 16, frA, "")
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	db.Close()
+
+	// Mixing the key-value store of one with the freezer of another should fail
+	if _, err := NewLevelDBDatabaseWithFreezer(kvA, 16, 16, frB, ""); err == nil {
+		t.Fatalf("mismatching database combination accepted")
+	}
+}
+
+// Tests that stamping a legacy database with an identifier recovers from the
+// freezer being unwritable or from being interrupted midway, instead of leaving
+// the two stores permanently mismatched.
+func TestDatabaseIDStampRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdb-id-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a legacy key-value store and a freezer, neither of them tagged
+	frdb, err := newFreezer(filepath.Join(dir, "ancient"), "")
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	db := memorydb.New()
+	db.Put(headerHashKey(0), common.Hash{0x01}.Bytes())
+
+	// Fail writing the freezer identifier, the key-value store must stay untagged
+	obstacle := filepath.Join(frdb.datadir, freezerIDFile+".tmp")
+	if err := os.Mkdir(obstacle, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateDatabaseID(db, frdb); err == nil {
+		t.Fatalf("stamping succeeded with unwritable freezer")
+	}
+	if id := ReadDatabaseID(db); id != nil {
+		t.Fatalf("key-value store tagged before the freezer: %x", id)
+	}
+	os.Remove(obstacle)
+
+	// Simulate a crash after tagging the freezer but before the key-value store
+	pending, _ := db.Get(databaseIDPendingKey)
+	if len(pending) == 0 {
+		t.Fatalf("pending identifier not tracked")
+	}
+	if err := frdb.writeID(pending); err != nil {
+		t.Fatalf("failed to tag freezer: %v", err)
+	}
+	if err := validateDatabaseID(db, frdb); err != nil {
+		t.Fatalf("failed to finish interrupted stamping: %v", err)
+	}
+	if id := ReadDatabaseID(db); !bytes.Equal(id, pending) {
+		t.Fatalf("database id mismatch: have %x, want %x", id, pending)
+	}
+	if blob, _ := db.Get(databaseIDPendingKey); len(blob) != 0 {
+		t.Fatalf("pending identifier not dropped")
+	}
+	// Consecutive validations must succeed too
+	if err := validateDatabaseID(db, frdb); err != nil {
+		t.Fatalf("failed to revalidate database id: %v", err)
+	}
+}
+
+// Tests that failures reading the freezer identifier are reported instead of
+// treating the freezer as untagged.
+func TestDatabaseIDReadFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdb-id-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	frdb, err := newFreezer(filepath.Join(dir, "ancient"), "")
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(frdb.datadir, freezerIDFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := frdb.readID(); err == nil {
+		t.Fatalf("unreadable freezer identifier accepted")
+	}
+	if err := validateDatabaseID(memorydb.New(), frdb); err == nil {
+		t.Fatalf("validation succeeded with unreadable freezer identifier")
+	}
+}
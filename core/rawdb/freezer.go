@@ -19,6 +19,7 @@ package rawdb
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -48,6 +49,10 @@ var (
 )
 
 const (
+	// freezerIDFile is the name of the file within the freezer directory holding
+	// the unique identifier of the database the freezer belongs to.
+	freezerIDFile = "DBID"
+
 	// freezerRecheckInterval is the frequency to check the key-value database for
 	// chain progression that might permit new blocks to be frozen into immutable
 	// storage.
@@ -71,6 +76,7 @@ type freezer struct {
 	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
 	frozen uint64 // Number of blocks already frozen
 
+	datadir      string                   // Directory containing the freezer files
 	tables       map[string]*freezerTable // Data tables for storing everything
 	instanceLock fileutil.Releaser        // File-system lock to prevent double opens
 	quit         chan struct{}
@@ -100,6 +106,7 @@ func newFreezer(datadir string, namespace string) (*freezer, error) {
 	}
 	// Open all the supported data tables
 	freezer := &freezer{
+		datadir:      datadir,
 		tables:       make(map[string]*freezerTable),
 		instanceLock: lock,
 		quit:         make(chan struct{}),
@@ -144,6 +151,50 @@ func (f *freezer) Close() error {
 	return nil
 }
 
+// readID retrieves the unique identifier of the database the freezer belongs
+// to, or nil if none was stored yet.
+func (f *freezer) readID() ([]byte, error) {
+	id, err := ioutil.ReadFile(filepath.Join(f.datadir, freezerIDFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(id) == 0 {
+		return nil, nil
+	}
+	return id, nil
+}
+
+// writeID atomically stores the unique identifier of the database the freezer
+// belongs to, so a crash never leaves a truncated identifier behind.
+func (f *freezer) writeID(id []byte) error {
+	var (
+		path = filepath.Join(f.datadir, freezerIDFile)
+		temp = path + ".tmp"
+	)
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(id); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
 // HasAncient returns an indicator whether the specified ancient data exists
 // in the freezer.
 func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
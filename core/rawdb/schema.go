@@ -29,6 +29,16 @@ var (
 	// databaseVerisionKey tracks the current database version.
 	databaseVerisionKey = []byte("DatabaseVersion")
 
+	// databaseIDKey tracks the unique identifier shared with the ancient store.
+	databaseIDKey = []byte("DatabaseID")
+
+	// databaseIDPendingKey tracks a unique identifier being stamped, until both
+	// the key-value store and the ancient store have it.
+	databaseIDPendingKey = []byte("DatabaseIDPending")
+
+	// stateVerifyProgressKey tracks the progress of an interrupted state verification.
+	stateVerifyProgressKey = []byte("StateVerifyProgress")
+
 	// headHeaderKey tracks the latest known header's hash.
 	headHeaderKey = []byte("LastHeader")
 
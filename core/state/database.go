@@ -28,6 +28,9 @@ import (
 const (
 	// Number of codehash->size associations to keep.
 	codeSizeCacheSize = 100000
+
+	// Number of account and storage proofs to cache.
+	proofCacheSize = 1024
 )
 
 // Database wraps access to tries and contract code.
@@ -100,16 +103,29 @@ type Trie interface {
 // concurrent use, but does not retain any recent trie nodes in memory. To keep some
 // historical state in memory, use the NewDatabaseWithCache constructor.
 func NewDatabase(db ethdb.Database) Database {
-	return NewDatabaseWithCache(db, 0)
+	return NewDatabaseWithCache(db, 0, "")
 }
 
 // NewDatabaseWithCache creates a backing store for state. The returned database
 // is safe for concurrent use and retains a lot of collapsed RLP trie nodes in a
-// large memory cache.
-func NewDatabaseWithCache(db ethdb.Database, cache int) Database {
+// large memory cache. If a journal is specified, the memory cache is loaded from
+// it on creation.
+func NewDatabaseWithCache(db ethdb.Database, cache int, journal string) Database {
+	return NewDatabaseWithConfig(db, &trie.Config{Cache: cache, Journal: journal})
+}
+
+// NewDatabaseWithConfig creates a backing store for state, with its trie
+// database configured with the given options. Unless requested otherwise, the
+// default number of merkle proofs is cached.
+func NewDatabaseWithConfig(db ethdb.Database, config *trie.Config) Database {
+	if config.Proofs == 0 {
+		conf := *config
+		conf.Proofs = proofCacheSize
+		config = &conf
+	}
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
-		db:            trie.NewDatabaseWithCache(db, cache),
+		db:            trie.NewDatabaseWithConfig(db, config),
 		codeSizeCache: csc,
 	}
 }
@@ -0,0 +1,141 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// verifyProgressInterval is the number of accounts after which the progress of
+// a state verification is persisted, allowing it to be resumed.
+const verifyProgressInterval = 10000
+
+// VerifyState iterates the account trie rooted at root and all the storage tries
+// it references, re-hashing every node loaded from disk and checking it against
+// the hash its parent references it by, as well as every contract code against
+// its code hash. This allows detecting silent disk corruption.
+//
+// The progress is periodically persisted into the database, so if verification
+// of the same root is interrupted, it resumes from the last account visited.
+func VerifyState(db ethdb.KeyValueStore, root common.Hash) (err error) {
+	// Decoding corrupted nodes panics deep in the trie, report them as errors
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupted state: %v", r)
+		}
+	}()
+	// Open the state through an uncached trie database, so every node is read
+	// from disk, and resume any interrupted verification of the same root
+	triedb := trie.NewDatabase(db)
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	var start []byte
+	if progress, marker := rawdb.ReadStateVerifyProgress(db); progress == root {
+		start = marker
+		log.Info("Resuming state verification", "root", root, "at", common.BytesToHash(marker))
+	}
+	var (
+		accounts, nodes, slots, codes int
+		begin                         = time.Now()
+		logged                        = time.Now()
+	)
+	it := tr.NodeIterator(start)
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if err := verifyNode(db, hash, it.Path()); err != nil {
+				return err
+			}
+			nodes++
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var acc Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			return fmt.Errorf("invalid account %x: %v", it.LeafKey(), err)
+		}
+		if acc.Root != emptyRoot {
+			storage, err := trie.New(acc.Root, triedb)
+			if err != nil {
+				return fmt.Errorf("missing storage trie of account %x: %v", it.LeafKey(), err)
+			}
+			sit := storage.NodeIterator(nil)
+			for sit.Next(true) {
+				if hash := sit.Hash(); hash != (common.Hash{}) {
+					if err := verifyNode(db, hash, sit.Path()); err != nil {
+						return fmt.Errorf("account %x storage: %v", it.LeafKey(), err)
+					}
+					nodes++
+				}
+				if sit.Leaf() {
+					slots++
+				}
+			}
+			if sit.Error() != nil {
+				return fmt.Errorf("account %x storage: %v", it.LeafKey(), sit.Error())
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCodeHash) {
+			code, _ := db.Get(acc.CodeHash)
+			if !bytes.Equal(crypto.Keccak256(code), acc.CodeHash) {
+				return fmt.Errorf("corrupted code %x of account %x", acc.CodeHash, it.LeafKey())
+			}
+			codes++
+		}
+		accounts++
+		if accounts%verifyProgressInterval == 0 {
+			rawdb.WriteStateVerifyProgress(db, root, it.LeafKey())
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying state", "root", root, "at", common.BytesToHash(it.LeafKey()), "accounts", accounts,
+				"slots", slots, "nodes", nodes, "codes", codes, "elapsed", common.PrettyDuration(time.Since(begin)))
+			logged = time.Now()
+		}
+	}
+	if it.Error() != nil {
+		return it.Error()
+	}
+	rawdb.DeleteStateVerifyProgress(db)
+	log.Info("Verified state", "root", root, "accounts", accounts, "slots", slots, "nodes", nodes,
+		"codes", codes, "elapsed", common.PrettyDuration(time.Since(begin)))
+	return nil
+}
+
+// verifyNode checks that the trie node stored on disk under the given hash, as
+// referenced by its parent, indeed hashes to it.
+func verifyNode(db ethdb.KeyValueReader, hash common.Hash, path []byte) error {
+	blob, err := db.Get(hash[:])
+	if err != nil || len(blob) == 0 {
+		return fmt.Errorf("missing trie node %x (path %x)", hash, path)
+	}
+	if have := crypto.Keccak256Hash(blob); have != hash {
+		return fmt.Errorf("corrupted trie node %x (path %x): hashes to %x", hash, path, have)
+	}
+	return nil
+}
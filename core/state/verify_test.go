@@ -0,0 +1,127 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeVerifyState creates a persisted state with a number of accounts, one of
+// them holding code and storage, returning the root and the storage root.
+func makeVerifyState() (*memorydb.Database, common.Hash, common.Hash, []byte) {
+	var (
+		diskdb     = memorydb.New()
+		statedb, _ = New(common.Hash{}, NewDatabase(rawdb.NewDatabase(diskdb)), nil)
+		contract   = common.BytesToAddress([]byte{0xc0, 0xde})
+		code       = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	)
+	for i := byte(1); i <= 64; i++ {
+		statedb.AddBalance(common.BytesToAddress([]byte{i}), big.NewInt(int64(i)))
+	}
+	statedb.SetCode(contract, code)
+	for i := byte(1); i <= 64; i++ {
+		statedb.SetState(contract, common.BytesToHash([]byte{i}), common.BytesToHash([]byte{i}))
+	}
+	root, _ := statedb.Commit(false)
+	statedb.Database().TrieDB().Commit(root, false)
+
+	statedb, _ = New(root, NewDatabase(rawdb.NewDatabase(diskdb)), nil)
+	return diskdb, root, statedb.StorageTrie(contract).Hash(), code
+}
+
+// Tests that state verification passes on intact state and detects corrupted
+// account trie nodes, storage trie nodes and contract codes.
+func TestVerifyState(t *testing.T) {
+	diskdb, root, storage, code := makeVerifyState()
+	if err := VerifyState(diskdb, root); err != nil {
+		t.Fatalf("failed to verify intact state: %v", err)
+	}
+	// corrupt replaces a stored blob, verifies the state and restores the blob
+	corrupt := func(key []byte, blob []byte) error {
+		old, _ := diskdb.Get(key)
+		diskdb.Put(key, blob)
+		defer diskdb.Put(key, old)
+
+		return VerifyState(diskdb, root)
+	}
+	// Pick a hashed child node of the account and of the storage trie
+	child := func(root common.Hash) common.Hash {
+		tr, _ := trie.New(root, trie.NewDatabase(diskdb))
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+			if hash := it.Hash(); hash != (common.Hash{}) && hash != root {
+				return hash
+			}
+		}
+		t.Fatalf("no hashed child node in trie %x", root)
+		return common.Hash{}
+	}
+	accountNode, storageNode := child(root), child(storage)
+	storageBlob, _ := diskdb.Get(storageNode[:])
+
+	// Replacing a node with another valid one must be caught by its hash link
+	if err := corrupt(accountNode[:], storageBlob); err == nil {
+		t.Errorf("corrupted account trie node not detected")
+	}
+	if err := corrupt(storage[:], storageBlob); err == nil {
+		t.Errorf("corrupted storage trie root not detected")
+	}
+	// Undecodable nodes must be reported, not crash
+	if err := corrupt(storageNode[:], []byte{0x01, 0x02, 0x03}); err == nil {
+		t.Errorf("undecodable storage trie node not detected")
+	}
+	// Code not matching its hash must be detected
+	codeHash := crypto.Keccak256(code)
+	if err := corrupt(codeHash, []byte{0x00}); err == nil {
+		t.Errorf("corrupted code not detected")
+	}
+	if err := VerifyState(diskdb, root); err != nil {
+		t.Fatalf("failed to verify restored state: %v", err)
+	}
+}
+
+// Tests that an interrupted state verification resumes from its persisted
+// progress marker and drops the marker once finished.
+func TestVerifyStateResume(t *testing.T) {
+	diskdb, root, storage, _ := makeVerifyState()
+
+	// Corrupt the storage trie, which lives somewhere in the account key space
+	storageBlob, _ := diskdb.Get(storage[:])
+	diskdb.Put(storage[:], append(storageBlob, 0x00))
+
+	// Resuming past every account must skip the corruption and finish
+	rawdb.WriteStateVerifyProgress(diskdb, root, bytes.Repeat([]byte{0xff}, common.HashLength))
+	if err := VerifyState(diskdb, root); err != nil {
+		t.Fatalf("resumed verification visited already verified accounts: %v", err)
+	}
+	if progress, marker := rawdb.ReadStateVerifyProgress(diskdb); progress != (common.Hash{}) || marker != nil {
+		t.Fatalf("progress not dropped after verification: %x %x", progress, marker)
+	}
+	// Progress recorded for a different root must be ignored
+	rawdb.WriteStateVerifyProgress(diskdb, common.Hash{0x01}, bytes.Repeat([]byte{0xff}, common.HashLength))
+	if err := VerifyState(diskdb, root); err == nil {
+		t.Fatalf("progress of a different root was resumed")
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Witness is the collection of chain and state data touched while executing a
+// block, sufficient to re-execute it without access to any local database.
+type Witness struct {
+	Headers []*types.Header // Parent header first, followed by any ancestors accessed via BLOCKHASH
+	Nodes   [][]byte        // Trie nodes and contract codes read, sorted by hash and deduplicated
+}
+
+// witnessRecorder is a key-value store which serves all reads from a trie
+// database, recording every blob retrieved. Writes are discarded into a
+// throwaway memory database, as state is never committed during recording.
+type witnessRecorder struct {
+	ethdb.KeyValueStore
+
+	source *trie.Database
+	nodes  map[common.Hash][]byte
+	lock   sync.Mutex
+}
+
+// Has retrieves if a node or code blob is present in the source database.
+func (r *witnessRecorder) Has(key []byte) (bool, error) {
+	blob, err := r.Get(key)
+	return blob != nil && err == nil, nil
+}
+
+// Get retrieves a node or code blob from the source database, recording it
+// into the witness.
+func (r *witnessRecorder) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return nil, errors.New("not found")
+	}
+	hash := common.BytesToHash(key)
+	blob, err := r.source.Node(hash)
+	if err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	r.nodes[hash] = common.CopyBytes(blob)
+	r.lock.Unlock()
+	return blob, nil
+}
+
+// witnessChain is a chain context which records all the headers retrieved
+// while executing a block.
+type witnessChain struct {
+	*BlockChain
+
+	headers map[common.Hash]*types.Header
+	lock    sync.Mutex
+}
+
+// record adds a header to the set of accessed ones, if it exists.
+func (c *witnessChain) record(header *types.Header) *types.Header {
+	if header != nil {
+		c.lock.Lock()
+		c.headers[header.Hash()] = header
+		c.lock.Unlock()
+	}
+	return header
+}
+
+// GetHeader retrieves a block header by hash and number, recording it.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.record(c.BlockChain.GetHeader(hash, number))
+}
+
+// GetHeaderByHash retrieves a block header by hash, recording it.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.record(c.BlockChain.GetHeaderByHash(hash))
+}
+
+// GetHeaderByNumber retrieves a block header by number, recording it.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	return c.record(c.BlockChain.GetHeaderByNumber(number))
+}
+
+// RecordWitness re-executes a block on top of its locally available parent
+// state, collecting every header, trie node and contract code accessed into a
+// witness which can be used to execute the block statelessly.
+func (bc *BlockChain) RecordWitness(block *types.Block) (*Witness, error) {
+	witness, _, err := bc.recordWitness(block, false)
+	return witness, err
+}
+
+// recordWitness re-executes a block on top of its locally available parent
+// state, collecting its witness. If requested, the post-state is committed too
+// and the trie nodes and contract codes written are returned, sorted by hash.
+func (bc *BlockChain) recordWitness(block *types.Block, commit bool) (*Witness, [][]byte, error) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, nil, consensus.ErrUnknownAncestor
+	}
+	// Open the parent state through an uncached trie database, so that every
+	// node and code access falls through to the recorder
+	recorder := &witnessRecorder{
+		KeyValueStore: memorydb.New(),
+		source:        bc.stateCache.TrieDB(),
+		nodes:         make(map[common.Hash][]byte),
+	}
+	database := state.NewDatabase(rawdb.NewDatabase(recorder))
+	statedb, err := state.New(parent.Root, database, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain := &witnessChain{
+		BlockChain: bc,
+		headers:    make(map[common.Hash]*types.Header),
+	}
+	if _, _, _, err := processBlock(bc.chainConfig, chain, bc.engine, block, statedb, vm.Config{}); err != nil {
+		return nil, nil, err
+	}
+	// Resolve the post state root too, as a stateless executor needs all the
+	// nodes touched while hashing the modified tries. If the written nodes are
+	// also requested, commit the state and flush the new nodes into the write
+	// side of the recorder, which only ever receives this block's writes.
+	var written [][]byte
+	if commit {
+		root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+		if err == nil {
+			err = statedb.Error()
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to record witness: %v", err)
+		}
+		if root != block.Root() {
+			return nil, nil, fmt.Errorf("witness post-state root mismatch: have %x, want %x", root, block.Root())
+		}
+		if err := database.TrieDB().Commit(root, false); err != nil {
+			return nil, nil, err
+		}
+		it := recorder.KeyValueStore.NewIterator(nil, nil)
+		for it.Next() {
+			if len(it.Key()) == common.HashLength { // Skip preimages
+				written = append(written, common.CopyBytes(it.Value()))
+			}
+		}
+		it.Release()
+	} else {
+		statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number()))
+		if err := statedb.Error(); err != nil {
+			return nil, nil, fmt.Errorf("failed to record witness: %v", err)
+		}
+	}
+	// Package the collected data into a sorted, deduplicated witness
+	witness := &Witness{Headers: []*types.Header{parent}}
+	delete(chain.headers, parent.Hash())
+
+	for _, header := range chain.headers {
+		witness.Headers = append(witness.Headers, header)
+	}
+	ancestors := witness.Headers[1:]
+	sort.Slice(ancestors, func(i, j int) bool {
+		return ancestors[i].Number.Uint64() > ancestors[j].Number.Uint64()
+	})
+	hashes := make([]common.Hash, 0, len(recorder.nodes))
+	for hash := range recorder.nodes {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	for _, hash := range hashes {
+		witness.Nodes = append(witness.Nodes, recorder.nodes[hash])
+	}
+	return witness, written, nil
+}
+
+// postWitness records the witness of a freshly imported block along with the
+// nodes written by it and feeds it to all the witness subscribers.
+func (bc *BlockChain) postWitness(block *types.Block) {
+	witness, written, err := bc.recordWitness(block, true)
+	if err != nil {
+		log.Error("Failed to record block witness", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return
+	}
+	bc.witnessFeed.Send(WitnessEvent{Block: block, Witness: witness, Written: written})
+}
+
+// witnessSubscription wraps a witness feed subscription to track the number of
+// live subscribers, so that witnesses are only recorded if someone listens.
+type witnessSubscription struct {
+	event.Subscription
+
+	bc   *BlockChain
+	once sync.Once
+}
+
+// Unsubscribe stops delivering witnesses and releases the subscriber slot.
+func (s *witnessSubscription) Unsubscribe() {
+	s.once.Do(func() { atomic.AddInt32(&s.bc.witnessSubs, -1) })
+	s.Subscription.Unsubscribe()
+}
+
+// SubscribeWitnessEvent registers a subscription of WitnessEvent, delivering the
+// pre-state read and post-state written by every block imported, e.g. to feed
+// external provers. Recording requires re-executing each block, so it is only
+// done while there are subscribers, which should consume events promptly as the
+// import blocks on delivery.
+func (bc *BlockChain) SubscribeWitnessEvent(ch chan<- WitnessEvent) event.Subscription {
+	atomic.AddInt32(&bc.witnessSubs, 1)
+	return bc.scope.Track(&witnessSubscription{Subscription: bc.witnessFeed.Subscribe(ch), bc: bc})
+}
+
+// statelessChain is a chain context backed solely by the headers contained in
+// a witness. Headers are indexed by their own hash, so a witness cannot smuggle
+// in a header under a different identity.
+type statelessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	parent  *types.Header
+	headers map[common.Hash]*types.Header
+}
+
+// Config retrieves the chain configuration.
+func (c *statelessChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the consensus engine.
+func (c *statelessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader retrieves the parent of the block being executed.
+func (c *statelessChain) CurrentHeader() *types.Header { return c.parent }
+
+// GetHeader retrieves a witness header by hash and number.
+func (c *statelessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a witness header by hash.
+func (c *statelessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+// GetHeaderByNumber retrieves a witness header by number, following the parent
+// links back from the block being executed.
+func (c *statelessChain) GetHeaderByNumber(number uint64) *types.Header {
+	for header := c.parent; header != nil; header = c.headers[header.ParentHash] {
+		if n := header.Number.Uint64(); n <= number {
+			if n == number {
+				return header
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// GetBlock is unsupported, witnesses contain no block bodies.
+func (c *statelessChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
+// ExecuteStateless executes a block using only the data contained in its
+// witness, without access to any local chain or state database, and verifies
+// the resulting post-state root, gas usage, bloom and receipts against the
+// block header.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *Witness) (err error) {
+	// The witness is untrusted and decoding invalid nodes panics deep in the
+	// trie, report them as errors
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid witness: %v", r)
+		}
+	}()
+	if witness == nil {
+		return errors.New("missing witness")
+	}
+	// Without a chain, the body can't be validated in full, but it must at least
+	// be the one committed to by the header, otherwise some other block is what
+	// actually gets verified
+	if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, block.TxHash())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return fmt.Errorf("uncle root hash mismatch: have %x, want %x", hash, block.UncleHash())
+	}
+	if len(witness.Headers) == 0 {
+		return errors.New("witness contains no parent header")
+	}
+	parent := witness.Headers[0]
+	if parent.Hash() != block.ParentHash() || parent.Number.Uint64()+1 != block.NumberU64() {
+		return fmt.Errorf("witness parent mismatch: have %x, want %x", parent.Hash(), block.ParentHash())
+	}
+	chain := &statelessChain{
+		config:  config,
+		engine:  engine,
+		parent:  parent,
+		headers: make(map[common.Hash]*types.Header),
+	}
+	for _, header := range witness.Headers {
+		chain.headers[header.Hash()] = header
+	}
+	// Build an in-memory state database purely from the witness blobs
+	db := memorydb.New()
+	for _, blob := range witness.Nodes {
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	statedb, err := state.New(parent.Root, state.NewDatabase(rawdb.NewDatabase(db)), nil)
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := processBlock(config, chain, engine, block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	err = NewBlockValidator(config, nil, engine).ValidateState(block, statedb, receipts, usedGas)
+
+	// Missing state is only flagged by the database, not by the execution, so
+	// report it in favour of the validation failure it most probably caused
+	if dbErr := statedb.Error(); dbErr != nil {
+		return fmt.Errorf("incomplete witness: %v", dbErr)
+	}
+	return err
+}
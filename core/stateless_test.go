@@ -0,0 +1,233 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newWitnessTestChain creates a short chain whose last block transfers some
+// ether and calls a contract storing the hash of its third ancestor.
+func newWitnessTestChain(t *testing.T) (*BlockChain, []*types.Block, []byte) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr     = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		code     = common.FromHex("0x600343034060005500") // sstore(0, blockhash(number - 3))
+		db       = rawdb.NewMemoryDatabase()
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				addr:     {Balance: big.NewInt(1000000000000000000)},
+				contract: {Code: code, Balance: big.NewInt(0)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	// Import a few empty blocks into an archive chain, so the contract call can
+	// be generated on top with access to the ancestor headers and state
+	config := &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true, TrieTimeLimit: 5 * time.Minute}
+	chain, err := NewBlockChain(db, config, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	last, _ := GenerateChain(gspec.Config, blocks[3], ethash.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		tx1, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		tx2, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr)+1, contract, big.NewInt(0), 100000, nil, nil), signer, key)
+		gen.AddTxWithChain(chain, tx1)
+		gen.AddTxWithChain(chain, tx2)
+	})
+	blocks = append(blocks, last...)
+	if _, err := chain.InsertChain(last); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain, blocks, code
+}
+
+// Tests that a witness recorded for a block contains the accessed headers and
+// a sorted, deduplicated set of the state blobs.
+func TestRecordWitness(t *testing.T) {
+	chain, blocks, code := newWitnessTestChain(t)
+	defer chain.Stop()
+
+	witness, err := chain.RecordWitness(blocks[4])
+	if err != nil {
+		t.Fatalf("failed to record witness: %v", err)
+	}
+	// The parent must come first, followed by the ancestors needed for BLOCKHASH
+	if len(witness.Headers) != 2 {
+		t.Fatalf("header count mismatch: have %d, want %d", len(witness.Headers), 2)
+	}
+	if have, want := witness.Headers[0].Hash(), blocks[3].Hash(); have != want {
+		t.Errorf("parent header mismatch: have %x, want %x", have, want)
+	}
+	if have, want := witness.Headers[1].Hash(), blocks[2].Hash(); have != want {
+		t.Errorf("ancestor header mismatch: have %x, want %x", have, want)
+	}
+	// The state blobs must be sorted by hash and must include the contract code
+	var (
+		prev  []byte
+		found bool
+	)
+	for i, blob := range witness.Nodes {
+		hash := crypto.Keccak256(blob)
+		if prev != nil && bytes.Compare(prev, hash) >= 0 {
+			t.Fatalf("blob %d: not strictly sorted by hash", i)
+		}
+		prev = hash
+		if bytes.Equal(blob, code) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("contract code missing from witness")
+	}
+	if len(witness.Nodes) < 3 {
+		t.Errorf("too few state blobs recorded: %d", len(witness.Nodes))
+	}
+}
+
+// Tests that a block can be verified purely from its witness, and that
+// incomplete witnesses or tampered blocks are rejected.
+func TestExecuteStateless(t *testing.T) {
+	chain, blocks, _ := newWitnessTestChain(t)
+	defer chain.Stop()
+
+	block := blocks[4]
+	witness, err := chain.RecordWitness(block)
+	if err != nil {
+		t.Fatalf("failed to record witness: %v", err)
+	}
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), block, witness); err != nil {
+		t.Fatalf("failed to execute block statelessly: %v", err)
+	}
+	// Dropping any single blob from the witness must fail execution
+	for i := range witness.Nodes {
+		partial := &Witness{Headers: witness.Headers}
+		partial.Nodes = append(partial.Nodes, witness.Nodes[:i]...)
+		partial.Nodes = append(partial.Nodes, witness.Nodes[i+1:]...)
+		if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), block, partial); err == nil {
+			t.Errorf("blob %d: execution succeeded with incomplete witness", i)
+		}
+	}
+	// Dropping the ancestor header makes BLOCKHASH resolve differently
+	partial := &Witness{Headers: witness.Headers[:1], Nodes: witness.Nodes}
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), block, partial); err == nil {
+		t.Errorf("execution succeeded with missing ancestor header")
+	}
+	// A missing witness or a body not matching the header must be rejected
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), block, nil); err == nil {
+		t.Errorf("execution succeeded without witness")
+	}
+	mismatch := types.NewBlockWithHeader(block.Header()).WithBody(block.Transactions()[:1], nil)
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), mismatch, witness); err == nil || !strings.Contains(err.Error(), "transaction root") {
+		t.Errorf("mismatching transactions not rejected: %v", err)
+	}
+	mismatch = types.NewBlockWithHeader(block.Header()).WithBody(block.Transactions(), []*types.Header{blocks[0].Header()})
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), mismatch, witness); err == nil || !strings.Contains(err.Error(), "uncle root") {
+		t.Errorf("mismatching uncles not rejected: %v", err)
+	}
+	// Undecodable state referenced by the parent must be reported, not crash
+	junk := []byte{0x01, 0x02, 0x03}
+	parent := types.CopyHeader(blocks[3].Header())
+	parent.Root = crypto.Keccak256Hash(junk)
+	empty := types.NewBlockWithHeader(&types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		TxHash:     types.EmptyRootHash,
+		UncleHash:  types.EmptyUncleHash,
+		Difficulty: common.Big1,
+	})
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), empty, &Witness{Headers: []*types.Header{parent}, Nodes: [][]byte{junk}}); err == nil {
+		t.Errorf("execution succeeded with undecodable witness node")
+	}
+	// A block claiming a different post-state root must be rejected
+	header := block.Header()
+	header.Root = common.Hash{0x01}
+	tampered := block.WithSeal(header)
+	if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), tampered, witness); err == nil {
+		t.Errorf("execution succeeded with invalid state root")
+	}
+}
+
+// Tests that witness subscribers receive the pre-state read and the post-state
+// written by every imported block.
+func TestWitnessFeed(t *testing.T) {
+	chain, blocks, _ := newWitnessTestChain(t)
+	defer chain.Stop()
+
+	events := make(chan WitnessEvent, 1)
+	sub := chain.SubscribeWitnessEvent(events)
+	defer sub.Unsubscribe()
+
+	next, _ := GenerateChain(chain.Config(), blocks[4], ethash.NewFaker(), chain.db, 1, nil)
+	if _, err := chain.InsertChain(next); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Block.Hash() != next[0].Hash() {
+			t.Fatalf("witness block mismatch: have %x, want %x", ev.Block.Hash(), next[0].Hash())
+		}
+		if err := ExecuteStateless(chain.Config(), ethash.NewFaker(), ev.Block, ev.Witness); err != nil {
+			t.Fatalf("failed to execute block from fed witness: %v", err)
+		}
+		// The written nodes must be sorted by hash and contain the new state root
+		var (
+			prev  []byte
+			found bool
+		)
+		for i, blob := range ev.Written {
+			hash := crypto.Keccak256(blob)
+			if prev != nil && bytes.Compare(prev, hash) >= 0 {
+				t.Fatalf("written blob %d: not strictly sorted by hash", i)
+			}
+			prev = hash
+			if common.BytesToHash(hash) == next[0].Root() {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("post-state root node missing from written nodes")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("witness event not delivered")
+	}
+	// Unsubscribing must stop witness recording altogether
+	sub.Unsubscribe()
+	if subs := atomic.LoadInt32(&chain.witnessSubs); subs != 0 {
+		t.Fatalf("witness subscriber count mismatch: have %d, want %d", subs, 0)
+	}
+}
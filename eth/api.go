@@ -321,6 +321,15 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return nil, errors.New("unknown preimage")
 }
 
+// SetTrieCleanCache resizes the clean trie node cache to the given number of
+// megabytes at runtime, which allows operators to rebalance memory between the
+// trie and other caches without restarting the node. The resized cache is
+// refilled from the old one in the background, so both take up memory until
+// the new one is warm. A size of zero disables the clean cache right away.
+func (api *PrivateDebugAPI) SetTrieCleanCache(size int) error {
+	return api.eth.blockchain.StateCache().TrieDB().ResizeCleanCache(size)
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash  common.Hash            `json:"hash"`
@@ -183,13 +183,24 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:      config.TrieCleanCache,
+			TrieCleanRejournal:  config.TrieCleanCacheRejournal,
+			TrieCleanVerify:     config.TrieCleanCacheVerify,
 			TrieCleanNoPrefetch: config.NoPrefetch,
+			TrieWarmAccounts:    config.TrieWarmAccounts,
+			TrieWarmContracts:   config.TrieWarmContracts,
+			TrieWarmDepth:       config.TrieWarmDepth,
+			TrieWarmBudget:      config.TrieWarmBudget,
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
+			TrieMemoryLimit:     config.TrieMemoryLimit,
 			SnapshotLimit:       config.SnapshotCache,
 		}
 	)
+	// An empty journal disables it, don't resolve it into the instance directory
+	if config.TrieCleanCacheJournal != "" {
+		cacheConfig.TrieCleanJournal = ctx.ResolvePath(config.TrieCleanCacheJournal)
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
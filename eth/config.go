@@ -45,14 +45,18 @@ var DefaultConfig = Config{
 		DatasetsOnDisk:   2,
 		DatasetsLockMmap: false,
 	},
-	NetworkId:          1,
-	LightPeers:         100,
-	UltraLightFraction: 75,
-	DatabaseCache:      512,
-	TrieCleanCache:     256,
-	TrieDirtyCache:     256,
-	TrieTimeout:        60 * time.Minute,
-	SnapshotCache:      256,
+	NetworkId:               1,
+	LightPeers:              100,
+	UltraLightFraction:      75,
+	DatabaseCache:           512,
+	TrieCleanCache:          256,
+	TrieCleanCacheJournal:   "triecache",
+	TrieCleanCacheRejournal: 60 * time.Minute,
+	TrieWarmDepth:           4,
+	TrieWarmBudget:          100000,
+	TrieDirtyCache:          256,
+	TrieTimeout:             60 * time.Minute,
+	SnapshotCache:           256,
 	Miner: miner.Config{
 		GasFloor: 8000000,
 		GasCeil:  8000000,
@@ -127,10 +131,18 @@ type Config struct {
 	DatabaseCache      int
 	DatabaseFreezer    string
 
-	TrieCleanCache int
-	TrieDirtyCache int
-	TrieTimeout    time.Duration
-	SnapshotCache  int
+	TrieCleanCache          int
+	TrieCleanCacheJournal   string           `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts (empty to disable)
+	TrieCleanCacheRejournal time.Duration    `toml:",omitempty"` // Time interval to regenerate the journal for clean cache
+	TrieCleanCacheVerify    uint64           `toml:",omitempty"` // Verify every n-th clean cache hit against its hash (0 = journaled caches only, 1 = always)
+	TrieWarmAccounts        int              `toml:",omitempty"` // Number of top account trie levels to pre-load into the clean cache on startup
+	TrieWarmContracts       []common.Address `toml:",omitempty"` // Contracts whose storage tries to pre-load into the clean cache on startup
+	TrieWarmDepth           int              `toml:",omitempty"` // Number of top storage trie levels to pre-load for each hot contract
+	TrieWarmBudget          int              `toml:",omitempty"` // Maximum number of trie nodes to pre-load on startup (0 = unlimited)
+	TrieMemoryLimit         int              `toml:",omitempty"` // Process memory limit (MB) above which to shrink the trie caches (0 = disabled)
+	TrieDirtyCache          int
+	TrieTimeout             time.Duration
+	SnapshotCache           int
 
 	// Mining options
 	Miner miner.Config
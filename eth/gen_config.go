@@ -38,6 +38,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		DatabaseCache           int
 		DatabaseFreezer         string
 		TrieCleanCache          int
+		TrieCleanCacheJournal   string           `toml:",omitempty"`
+		TrieCleanCacheRejournal time.Duration    `toml:",omitempty"`
+		TrieCleanCacheVerify    uint64           `toml:",omitempty"`
+		TrieWarmAccounts        int              `toml:",omitempty"`
+		TrieWarmContracts       []common.Address `toml:",omitempty"`
+		TrieWarmDepth           int              `toml:",omitempty"`
+		TrieWarmBudget          int              `toml:",omitempty"`
+		TrieMemoryLimit         int              `toml:",omitempty"`
 		TrieDirtyCache          int
 		TrieTimeout             time.Duration
 		Miner                   miner.Config
@@ -75,6 +83,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DatabaseCache = c.DatabaseCache
 	enc.DatabaseFreezer = c.DatabaseFreezer
 	enc.TrieCleanCache = c.TrieCleanCache
+	enc.TrieCleanCacheJournal = c.TrieCleanCacheJournal
+	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
+	enc.TrieCleanCacheVerify = c.TrieCleanCacheVerify
+	enc.TrieWarmAccounts = c.TrieWarmAccounts
+	enc.TrieWarmContracts = c.TrieWarmContracts
+	enc.TrieWarmDepth = c.TrieWarmDepth
+	enc.TrieWarmBudget = c.TrieWarmBudget
+	enc.TrieMemoryLimit = c.TrieMemoryLimit
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
 	enc.Miner = c.Miner
@@ -114,6 +130,14 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		DatabaseCache           *int
 		DatabaseFreezer         *string
 		TrieCleanCache          *int
+		TrieCleanCacheJournal   *string          `toml:",omitempty"`
+		TrieCleanCacheRejournal *time.Duration   `toml:",omitempty"`
+		TrieCleanCacheVerify    *uint64          `toml:",omitempty"`
+		TrieWarmAccounts        *int             `toml:",omitempty"`
+		TrieWarmContracts       []common.Address `toml:",omitempty"`
+		TrieWarmDepth           *int             `toml:",omitempty"`
+		TrieWarmBudget          *int             `toml:",omitempty"`
+		TrieMemoryLimit         *int             `toml:",omitempty"`
 		TrieDirtyCache          *int
 		TrieTimeout             *time.Duration
 		Miner                   *miner.Config
@@ -194,6 +218,30 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
+	if dec.TrieCleanCacheJournal != nil {
+		c.TrieCleanCacheJournal = *dec.TrieCleanCacheJournal
+	}
+	if dec.TrieCleanCacheRejournal != nil {
+		c.TrieCleanCacheRejournal = *dec.TrieCleanCacheRejournal
+	}
+	if dec.TrieCleanCacheVerify != nil {
+		c.TrieCleanCacheVerify = *dec.TrieCleanCacheVerify
+	}
+	if dec.TrieWarmAccounts != nil {
+		c.TrieWarmAccounts = *dec.TrieWarmAccounts
+	}
+	if dec.TrieWarmContracts != nil {
+		c.TrieWarmContracts = dec.TrieWarmContracts
+	}
+	if dec.TrieWarmDepth != nil {
+		c.TrieWarmDepth = *dec.TrieWarmDepth
+	}
+	if dec.TrieWarmBudget != nil {
+		c.TrieWarmBudget = *dec.TrieWarmBudget
+	}
+	if dec.TrieMemoryLimit != nil {
+		c.TrieMemoryLimit = *dec.TrieMemoryLimit
+	}
 	if dec.TrieDirtyCache != nil {
 		c.TrieDirtyCache = *dec.TrieDirtyCache
 	}
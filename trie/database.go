@@ -21,40 +21,23 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/fastcache"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
 )
 
-var (
-	memcacheCleanHitMeter   = metrics.NewRegisteredMeter("trie/memcache/clean/hit", nil)
-	memcacheCleanMissMeter  = metrics.NewRegisteredMeter("trie/memcache/clean/miss", nil)
-	memcacheCleanReadMeter  = metrics.NewRegisteredMeter("trie/memcache/clean/read", nil)
-	memcacheCleanWriteMeter = metrics.NewRegisteredMeter("trie/memcache/clean/write", nil)
-
-	memcacheDirtyHitMeter   = metrics.NewRegisteredMeter("trie/memcache/dirty/hit", nil)
-	memcacheDirtyMissMeter  = metrics.NewRegisteredMeter("trie/memcache/dirty/miss", nil)
-	memcacheDirtyReadMeter  = metrics.NewRegisteredMeter("trie/memcache/dirty/read", nil)
-	memcacheDirtyWriteMeter = metrics.NewRegisteredMeter("trie/memcache/dirty/write", nil)
-
-	memcacheFlushTimeTimer  = metrics.NewRegisteredResettingTimer("trie/memcache/flush/time", nil)
-	memcacheFlushNodesMeter = metrics.NewRegisteredMeter("trie/memcache/flush/nodes", nil)
-	memcacheFlushSizeMeter  = metrics.NewRegisteredMeter("trie/memcache/flush/size", nil)
-
-	memcacheGCTimeTimer  = metrics.NewRegisteredResettingTimer("trie/memcache/gc/time", nil)
-	memcacheGCNodesMeter = metrics.NewRegisteredMeter("trie/memcache/gc/nodes", nil)
-	memcacheGCSizeMeter  = metrics.NewRegisteredMeter("trie/memcache/gc/size", nil)
-
-	memcacheCommitTimeTimer  = metrics.NewRegisteredResettingTimer("trie/memcache/commit/time", nil)
-	memcacheCommitNodesMeter = metrics.NewRegisteredMeter("trie/memcache/commit/nodes", nil)
-	memcacheCommitSizeMeter  = metrics.NewRegisteredMeter("trie/memcache/commit/size", nil)
-)
+// cleanJournalVerifyRate is the rate at which clean cache hits are verified
+// against their hashes if the cache was loaded from a journal on disk.
+const cleanJournalVerifyRate = 1024
 
 // secureKeyPrefix is the database key prefix used to store trie node preimages.
 var secureKeyPrefix = []byte("secure-key-")
@@ -74,14 +57,24 @@ const secureKeyLength = secureKeyPrefixLength + 32
 // behind this split design is to provide read access to RPC handlers and sync
 // servers even while the trie is executing expensive garbage collection.
 type Database struct {
+	// WARNING: The `verified` field is accessed atomically. On 32 bit platforms, only
+	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
+	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
+	verified   uint64 // Number of clean cache hits, used to sample verifications
+	cleansFill int64  // Bytes to cache after a resize before dropping the previous clean cache
+
 	diskdb ethdb.KeyValueStore // Persistent storage for matured trie nodes
 
-	cleans  *fastcache.Cache            // GC friendly memory cache of clean node RLPs
-	dirties map[common.Hash]*cachedNode // Data and references relationships of dirty nodes
-	oldest  common.Hash                 // Oldest tracked node, flush-list head
-	newest  common.Hash                 // Newest tracked node, flush-list tail
+	cleans     CleanCache                  // GC friendly memory cache of clean node RLPs (nil = disabled)
+	cleansPrev CleanCache                  // Clean cache replaced by a resize, read through until the new one is warm
+	cleansLock sync.RWMutex                // Lock preventing the clean caches from being swapped out while in use
+	verify     uint64                      // Verify every n-th clean cache hit against its hash (0 = never)
+	dirties    map[common.Hash]*cachedNode // Data and references relationships of dirty nodes
+	oldest     common.Hash                 // Oldest tracked node, flush-list head
+	newest     common.Hash                 // Newest tracked node, flush-list tail
 
 	preimages map[common.Hash][]byte // Preimages of nodes from the secure trie
+	proofs    *lru.Cache             // Merkle proofs generated against hashed tries
 
 	gctime  time.Duration      // Time spent on garbage collection since last commit
 	gcnodes uint64             // Nodes garbage collected since last commit
@@ -95,6 +88,8 @@ type Database struct {
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
 
+	metrics *databaseMetrics // Cache and flush statistics reported into the metrics sink
+
 	lock sync.RWMutex
 }
 
@@ -281,22 +276,231 @@ func NewDatabase(diskdb ethdb.KeyValueStore) *Database {
 	return NewDatabaseWithCache(diskdb, 0)
 }
 
+// CleanCache is the memory cache of clean trie node RLPs in front of the disk
+// database, keyed by node hash. It is satisfied by *fastcache.Cache, the cache
+// used by default, but allows plugging in alternative implementations.
+type CleanCache interface {
+	// Get appends the cached value of key to dst and returns the result.
+	Get(dst, key []byte) []byte
+
+	// Set stores the key-value pair in the cache.
+	Set(key, value []byte)
+
+	// Del removes the value of key from the cache.
+	Del(key []byte)
+
+	// Reset removes all items from the cache.
+	Reset()
+
+	// SaveToFileConcurrent persists the cache into the given directory, using
+	// the requested number of goroutines.
+	SaveToFileConcurrent(dir string, concurrency int) error
+}
+
+// Config defines all necessary options for database.
+type Config struct {
+	Cache      int         // Memory allowance (MB) to use for caching trie nodes in memory
+	Journal    string      // Journal of clean cache to survive node restarts
+	CleanCache CleanCache  // Externally managed clean cache (overrides Cache and Journal)
+	Proofs     int         // Number of merkle proofs to cache by root and key (0 = disabled)
+	Verify     uint64      // Verify every n-th clean cache hit against its hash (0 = never, 1 = always)
+	Metrics    MetricsSink // Metrics sink to report into (nil = global go-ethereum registry)
+}
+
 // NewDatabaseWithCache creates a new trie database to store ephemeral trie content
 // before its written out to disk or garbage collected. It also acts as a read cache
 // for nodes loaded from disk.
 func NewDatabaseWithCache(diskdb ethdb.KeyValueStore, cache int) *Database {
-	var cleans *fastcache.Cache
-	if cache > 0 {
-		cleans = fastcache.New(cache * 1024 * 1024)
+	return NewDatabaseWithConfig(diskdb, &Config{Cache: cache})
+}
+
+// NewDatabaseWithConfig creates a new trie database to store ephemeral trie
+// content before its written out to disk or garbage collected, configured with
+// the given options.
+func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database {
+	if config == nil {
+		config = new(Config)
 	}
-	return &Database{
+	var (
+		cleans CleanCache
+		verify = config.Verify
+	)
+	switch {
+	case config.CleanCache != nil:
+		cleans = config.CleanCache
+
+	case config.Cache > 0 && config.Journal == "":
+		cleans = fastcache.New(config.Cache * 1024 * 1024)
+
+	case config.Cache > 0:
+		cache := fastcache.LoadFromFileOrNew(config.Journal, config.Cache*1024*1024)
+
+		// A journal might have been corrupted on disk, sample the loaded nodes
+		// against their hashes unless verification was explicitly configured.
+		var stats fastcache.Stats
+		if cache.UpdateStats(&stats); stats.EntriesCount > 0 && verify == 0 {
+			verify = cleanJournalVerifyRate
+		}
+		cleans = cache
+	}
+	var proofs *lru.Cache
+	if config.Proofs > 0 {
+		proofs, _ = lru.New(config.Proofs)
+	}
+	db := &Database{
 		diskdb: diskdb,
 		cleans: cleans,
+		verify: verify,
 		dirties: map[common.Hash]*cachedNode{{}: {
 			children: make(map[common.Hash]uint16),
 		}},
 		preimages: make(map[common.Hash][]byte),
+		proofs:    proofs,
+		metrics:   newDatabaseMetrics(config.Metrics),
+	}
+	return db
+}
+
+// cleanCache returns the currently active clean cache, or nil if clean caching
+// is disabled.
+func (db *Database) cleanCache() CleanCache {
+	db.cleansLock.RLock()
+	defer db.cleansLock.RUnlock()
+
+	return db.cleans
+}
+
+// cleanSet inserts an encoded trie node into the clean cache, returning whether
+// clean caching is enabled at all.
+func (db *Database) cleanSet(hash common.Hash, enc []byte) bool {
+	db.cleansLock.RLock()
+	defer db.cleansLock.RUnlock()
+
+	if db.cleans == nil {
+		return false
 	}
+	db.cleans.Set(hash[:], enc)
+	db.cleanFilled(len(hash) + len(enc))
+	return true
+}
+
+// cleanFilled tracks the amount of data inserted into the clean cache since the
+// last resize, scheduling the previous cache to be dropped once the new one has
+// been filled. The method assumes that the clean cache read lock is held!
+func (db *Database) cleanFilled(size int) {
+	if db.cleansPrev == nil {
+		return
+	}
+	if left := atomic.AddInt64(&db.cleansFill, -int64(size)); left <= 0 && left+int64(size) > 0 {
+		go db.dropCleanCache(db.cleansPrev)
+	}
+}
+
+// dropCleanCache releases the previous clean cache replaced by a resize, unless
+// it was already released by a subsequent resize.
+func (db *Database) dropCleanCache(prev CleanCache) {
+	db.cleansLock.Lock()
+	defer db.cleansLock.Unlock()
+
+	if db.cleansPrev != prev {
+		return
+	}
+	db.cleansPrev = nil
+	prev.Reset()
+
+	log.Debug("Dropped previous clean trie cache")
+}
+
+// ResizeCleanCache replaces the default clean cache with a new one of the given
+// size in megabytes, or disables clean caching if the size is zero.
+//
+// The new cache is rebuilt in the background: the old one is kept and read
+// through on misses, moving the nodes found into the new cache, until as much
+// data was cached anew as the old one held (or the new one fits). Only then is
+// the memory of the old cache released, so until the new cache is warm, both
+// take up memory.
+//
+// Externally provided clean caches cannot be resized.
+func (db *Database) ResizeCleanCache(size int) error {
+	return db.resizeCleanCache(size, true)
+}
+
+// ResetCleanCache replaces the default clean cache with an empty one of the
+// given size in megabytes, or disables clean caching if the size is zero. As
+// opposed to ResizeCleanCache, nothing is carried over into the new cache and
+// the memory of the old one is released right away.
+//
+// Externally provided clean caches cannot be reset.
+func (db *Database) ResetCleanCache(size int) error {
+	return db.resizeCleanCache(size, false)
+}
+
+// resizeCleanCache replaces the default clean cache with a new one of the given
+// size, optionally refilling it in the background from the old one.
+func (db *Database) resizeCleanCache(size int, refill bool) error {
+	if size < 0 {
+		return fmt.Errorf("invalid clean cache size: %d", size)
+	}
+	// Hold the lock across the swap: every cache access happens under the read
+	// lock, so the old cache is guaranteed to be unused by the time it's reset,
+	// and concurrent resizes can't drop a cache without releasing its memory.
+	db.cleansLock.Lock()
+	defer db.cleansLock.Unlock()
+
+	old := db.cleans
+	if old != nil {
+		if _, ok := old.(*fastcache.Cache); !ok {
+			return errors.New("external clean cache cannot be resized")
+		}
+	}
+	// Fastcache memory lives off-heap and is only released on reset
+	if size == 0 || !refill {
+		for _, cache := range []CleanCache{old, db.cleansPrev} {
+			if cache != nil {
+				cache.Reset()
+			}
+		}
+		db.cleans, db.cleansPrev = nil, nil
+
+		if size == 0 {
+			log.Info("Disabled clean trie cache")
+			return nil
+		}
+		db.cleans = fastcache.New(size * 1024 * 1024)
+
+		log.Info("Reset clean trie cache", "size", common.StorageSize(size)*1024*1024)
+		return nil
+	}
+	db.cleans = fastcache.New(size * 1024 * 1024)
+
+	// If a previous resize is still warming up, its fallback is the warmest cache
+	// around, so keep that one instead of the one being replaced
+	if db.cleansPrev != nil {
+		if old != nil {
+			old.Reset()
+		}
+		old, db.cleansPrev = db.cleansPrev, nil
+	}
+	// Keep the old cache around until the new one has been filled with as much
+	// data as it held, capped at the new capacity
+	if old != nil {
+		var stats fastcache.Stats
+		old.(*fastcache.Cache).UpdateStats(&stats)
+
+		fill := int64(stats.BytesSize)
+		if limit := int64(size) * 1024 * 1024; fill > limit {
+			fill = limit
+		}
+		if fill > 0 {
+			db.cleansPrev = old
+			atomic.StoreInt64(&db.cleansFill, fill)
+		} else {
+			old.Reset()
+		}
+	}
+
+	log.Info("Resized clean trie cache", "size", common.StorageSize(size)*1024*1024)
+	return nil
 }
 
 // DiskDB retrieves the persistent storage backing the trie database.
@@ -324,7 +528,7 @@ func (db *Database) insert(hash common.Hash, size int, node node) {
 	if _, ok := db.dirties[hash]; ok {
 		return
 	}
-	memcacheDirtyWriteMeter.Mark(int64(size))
+	db.metrics.dirtyWrite.Mark(int64(size))
 
 	// Create the cached entry for this node
 	entry := &cachedNode{
@@ -364,12 +568,8 @@ func (db *Database) insertPreimage(hash common.Hash, preimage []byte) {
 // found in the memory cache.
 func (db *Database) node(hash common.Hash) node {
 	// Retrieve the node from the clean cache if available
-	if db.cleans != nil {
-		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
-			memcacheCleanHitMeter.Mark(1)
-			memcacheCleanReadMeter.Mark(int64(len(enc)))
-			return mustDecodeNode(hash[:], enc)
-		}
+	if enc := db.cleanBlob(hash); enc != nil {
+		return mustDecodeNode(hash[:], enc)
 	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
@@ -377,25 +577,64 @@ func (db *Database) node(hash common.Hash) node {
 	db.lock.RUnlock()
 
 	if dirty != nil {
-		memcacheDirtyHitMeter.Mark(1)
-		memcacheDirtyReadMeter.Mark(int64(dirty.size))
+		db.metrics.dirtyHit.Mark(1)
+		db.metrics.dirtyRead.Mark(int64(dirty.size))
 		return dirty.obj(hash)
 	}
-	memcacheDirtyMissMeter.Mark(1)
+	db.metrics.dirtyMiss.Mark(1)
 
 	// Content unavailable in memory, attempt to retrieve from disk
 	enc, err := db.diskdb.Get(hash[:])
 	if err != nil || enc == nil {
 		return nil
 	}
-	if db.cleans != nil {
-		db.cleans.Set(hash[:], enc)
-		memcacheCleanMissMeter.Mark(1)
-		memcacheCleanWriteMeter.Mark(int64(len(enc)))
+	if db.cleanSet(hash, enc) {
+		db.metrics.cleanMiss.Mark(1)
+		db.metrics.cleanWrite.Mark(int64(len(enc)))
 	}
 	return mustDecodeNode(hash[:], enc)
 }
 
+// cleanBlob retrieves an encoded trie node from the clean cache, or returns nil
+// if none can be found. If verification is enabled, the blob is checked against
+// the requested hash and evicted on mismatch, so that the caller falls back to
+// the dirty cache or the disk instead of serving corrupted data.
+func (db *Database) cleanBlob(hash common.Hash) []byte {
+	db.cleansLock.RLock()
+	defer db.cleansLock.RUnlock()
+
+	if db.cleans == nil {
+		return nil
+	}
+	enc := db.cleans.Get(nil, hash[:])
+	if enc == nil {
+		// If the cache was resized recently, fall back to the previous one and
+		// move the node across
+		if db.cleansPrev == nil {
+			return nil
+		}
+		if enc = db.cleansPrev.Get(nil, hash[:]); enc == nil {
+			return nil
+		}
+		db.cleans.Set(hash[:], enc)
+		db.cleanFilled(len(hash) + len(enc))
+	}
+	if db.verify > 0 && atomic.AddUint64(&db.verified, 1)%db.verify == 0 {
+		if have := crypto.Keccak256Hash(enc); have != hash {
+			log.Error("Corrupted trie node in clean cache", "hash", hash, "have", have)
+			db.metrics.cleanCorrupt.Mark(1)
+			db.cleans.Del(hash[:])
+			if db.cleansPrev != nil {
+				db.cleansPrev.Del(hash[:])
+			}
+			return nil
+		}
+	}
+	db.metrics.cleanHit.Mark(1)
+	db.metrics.cleanRead.Mark(int64(len(enc)))
+	return enc
+}
+
 // Node retrieves an encoded cached trie node from memory. If it cannot be found
 // cached, the method queries the persistent database for the content.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
@@ -404,12 +643,8 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 		return nil, errors.New("not found")
 	}
 	// Retrieve the node from the clean cache if available
-	if db.cleans != nil {
-		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
-			memcacheCleanHitMeter.Mark(1)
-			memcacheCleanReadMeter.Mark(int64(len(enc)))
-			return enc, nil
-		}
+	if enc := db.cleanBlob(hash); enc != nil {
+		return enc, nil
 	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
@@ -417,24 +652,73 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	db.lock.RUnlock()
 
 	if dirty != nil {
-		memcacheDirtyHitMeter.Mark(1)
-		memcacheDirtyReadMeter.Mark(int64(dirty.size))
+		db.metrics.dirtyHit.Mark(1)
+		db.metrics.dirtyRead.Mark(int64(dirty.size))
 		return dirty.rlp(), nil
 	}
-	memcacheDirtyMissMeter.Mark(1)
+	db.metrics.dirtyMiss.Mark(1)
 
 	// Content unavailable in memory, attempt to retrieve from disk
 	enc, err := db.diskdb.Get(hash[:])
 	if err == nil && enc != nil {
-		if db.cleans != nil {
-			db.cleans.Set(hash[:], enc)
-			memcacheCleanMissMeter.Mark(1)
-			memcacheCleanWriteMeter.Mark(int64(len(enc)))
+		if db.cleanSet(hash, enc) {
+			db.metrics.cleanMiss.Mark(1)
+			db.metrics.cleanWrite.Mark(int64(len(enc)))
 		}
 	}
 	return enc, err
 }
 
+// Warm pre-loads the top depth levels of the tries rooted at the given hashes
+// into the clean cache, avoiding the latency of cold disk reads on the hottest
+// parts of the state (e.g. the account trie or popular contract storage) after
+// a restart. Nodes missing from the database are silently skipped.
+//
+// The tries are loaded breadth first, so if at most budget nodes are permitted
+// (0 = unlimited), the shallowest (hottest) ones are preferred. Warming can be
+// aborted via the given channel. The number of nodes loaded is returned.
+func (db *Database) Warm(roots []common.Hash, depth int, budget int, abort <-chan struct{}) int {
+	if db.cleanCache() == nil {
+		return 0
+	}
+	var (
+		start  = time.Now()
+		nodes  int
+		level  = roots
+		loaded = make(map[common.Hash]struct{})
+	)
+	for d := 0; d < depth && len(level) > 0; d++ {
+		var next []common.Hash
+		for _, hash := range level {
+			if budget > 0 && nodes >= budget {
+				log.Debug("Trie clean cache warm-up budget exhausted", "nodes", nodes)
+				return nodes
+			}
+			select {
+			case <-abort:
+				return nodes
+			default:
+			}
+			if _, ok := loaded[hash]; ok || hash == (common.Hash{}) {
+				continue
+			}
+			loaded[hash] = struct{}{}
+
+			enc, err := db.Node(hash)
+			if err != nil {
+				continue
+			}
+			nodes++
+			forGatherChildren(simplifyNode(mustDecodeNode(hash[:], enc)), func(child common.Hash) {
+				next = append(next, child)
+			})
+		}
+		level = next
+	}
+	log.Debug("Warmed trie clean cache", "roots", len(roots), "depth", depth, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nodes
+}
+
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
 // found cached, the method queries the persistent database for the content.
 func (db *Database) preimage(hash common.Hash) ([]byte, error) {
@@ -521,9 +805,9 @@ func (db *Database) Dereference(root common.Hash) {
 	db.gcsize += storage - db.dirtiesSize
 	db.gctime += time.Since(start)
 
-	memcacheGCTimeTimer.Update(time.Since(start))
-	memcacheGCSizeMeter.Mark(int64(storage - db.dirtiesSize))
-	memcacheGCNodesMeter.Mark(int64(nodes - len(db.dirties)))
+	db.metrics.gcTime.Update(time.Since(start))
+	db.metrics.gcSize.Mark(int64(storage - db.dirtiesSize))
+	db.metrics.gcNodes.Mark(int64(nodes - len(db.dirties)))
 
 	log.Debug("Dereferenced trie from memory database", "nodes", nodes-len(db.dirties), "size", storage-db.dirtiesSize, "time", time.Since(start),
 		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.dirties), "livesize", db.dirtiesSize)
@@ -676,9 +960,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	db.flushsize += storage - db.dirtiesSize
 	db.flushtime += time.Since(start)
 
-	memcacheFlushTimeTimer.Update(time.Since(start))
-	memcacheFlushSizeMeter.Mark(int64(storage - db.dirtiesSize))
-	memcacheFlushNodesMeter.Mark(int64(nodes - len(db.dirties)))
+	db.metrics.flushTime.Update(time.Since(start))
+	db.metrics.flushSize.Mark(int64(storage - db.dirtiesSize))
+	db.metrics.flushNodes.Mark(int64(nodes - len(db.dirties)))
 
 	log.Debug("Persisted nodes from memory database", "nodes", nodes-len(db.dirties), "size", storage-db.dirtiesSize, "time", time.Since(start),
 		"flushnodes", db.flushnodes, "flushsize", db.flushsize, "flushtime", db.flushtime, "livenodes", len(db.dirties), "livesize", db.dirtiesSize)
@@ -751,9 +1035,9 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	db.preimages = make(map[common.Hash][]byte)
 	db.preimagesSize = 0
 
-	memcacheCommitTimeTimer.Update(time.Since(start))
-	memcacheCommitSizeMeter.Mark(int64(storage - db.dirtiesSize))
-	memcacheCommitNodesMeter.Mark(int64(nodes - len(db.dirties)))
+	db.metrics.commitTime.Update(time.Since(start))
+	db.metrics.commitSize.Mark(int64(storage - db.dirtiesSize))
+	db.metrics.commitNodes.Mark(int64(nodes - len(db.dirties)))
 
 	logger := log.Info
 	if !report {
@@ -839,9 +1123,8 @@ func (c *cleaner) Put(key []byte, rlp []byte) error {
 		c.db.dirtiesSize -= common.StorageSize(cachedNodeChildrenSize + len(node.children)*(common.HashLength+2))
 	}
 	// Move the flushed node into the clean cache to prevent insta-reloads
-	if c.db.cleans != nil {
-		c.db.cleans.Set(hash[:], rlp)
-		memcacheCleanWriteMeter.Mark(int64(len(rlp)))
+	if c.db.cleanSet(hash, rlp) {
+		c.db.metrics.cleanWrite.Mark(int64(len(rlp)))
 	}
 	return nil
 }
@@ -863,3 +1146,48 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	var metarootRefs = common.StorageSize(len(db.dirties[common.Hash{}].children) * (common.HashLength + 2))
 	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs, db.preimagesSize
 }
+
+// saveCache saves clean state cache to given directory path
+// using specified CPU cores.
+func (db *Database) saveCache(dir string, threads int) error {
+	// An empty journal path means journaling is disabled. Never hand it to the
+	// cache, as persisting replaces (and thus deletes) the target directory.
+	db.cleansLock.RLock()
+	defer db.cleansLock.RUnlock()
+
+	if db.cleans == nil || dir == "" {
+		return nil
+	}
+	log.Info("Writing clean trie cache to disk", "path", dir, "threads", threads)
+
+	start := time.Now()
+	err := db.cleans.SaveToFileConcurrent(dir, threads)
+	if err != nil {
+		log.Error("Failed to persist clean trie cache", "error", err)
+		return err
+	}
+	log.Info("Persisted the clean trie cache", "path", dir, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// SaveCache atomically saves fast cache data to the given dir using all
+// available CPU cores. If dir is empty, nothing is written.
+func (db *Database) SaveCache(dir string) error {
+	return db.saveCache(dir, runtime.GOMAXPROCS(0))
+}
+
+// SaveCachePeriodically atomically saves fast cache data to the given dir with
+// the specified interval. All dump operation will only use a single CPU core.
+func (db *Database) SaveCachePeriodically(dir string, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			db.saveCache(dir, 1)
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -17,10 +17,20 @@
 package trie
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 // Tests that the trie database returns a missing trie node error if attempting
@@ -31,3 +41,333 @@ func TestDatabaseMetarootFetch(t *testing.T) {
 		t.Fatalf("metaroot retrieval succeeded")
 	}
 }
+
+// registeredAtInit is the number of trie metrics found in the global registry
+// right after package initialization, before any test created a database.
+var registeredAtInit = countTrieMetrics(metrics.DefaultRegistry)
+
+// countTrieMetrics counts the trie package metrics registered in a registry.
+func countTrieMetrics(registry metrics.Registry) int {
+	var count int
+	registry.Each(func(name string, _ interface{}) {
+		if strings.HasPrefix(name, "trie/") {
+			count++
+		}
+	})
+	return count
+}
+
+// Tests that the trie database and the sync bloom register their metrics into
+// the configured sink instead of the global registry, and that the package does
+// not register any metrics on its own.
+func TestDatabaseMetricsSink(t *testing.T) {
+	if registeredAtInit != 0 {
+		t.Fatalf("trie metrics registered globally on package init: %d", registeredAtInit)
+	}
+	var (
+		registry = metrics.NewRegistry()
+		globals  = countTrieMetrics(metrics.DefaultRegistry)
+	)
+	NewDatabaseWithConfig(memorydb.New(), &Config{Metrics: NewRegistrySink(registry)})
+	NewSyncBloom(1, memorydb.New(), NewRegistrySink(registry)).Close()
+
+	for _, name := range []string{"trie/memcache/clean/hit", "trie/memcache/flush/time", "trie/memcache/commit/size", "trie/bloom/fault", "trie/bloom/error"} {
+		if registry.Get(name) == nil {
+			t.Errorf("metric %q not registered in custom sink", name)
+		}
+	}
+	if have := countTrieMetrics(metrics.DefaultRegistry); have != globals {
+		t.Errorf("trie metrics registered globally: have %d, want %d", have, globals)
+	}
+}
+
+// newTestNodeDB creates a disk database containing a single trie node, returning
+// it along with the hash and blob of the stored node.
+func newTestNodeDB() (*memorydb.Database, common.Hash, []byte) {
+	diskdb := memorydb.New()
+	blob := []byte{0xc2, 0x20, 0x01}
+	hash := crypto.Keccak256Hash(blob)
+	diskdb.Put(hash[:], blob)
+	return diskdb, hash, blob
+}
+
+// Tests that corrupted clean cache entries are detected when verification is
+// enabled, and that the node is reloaded from disk instead.
+func TestDatabaseCleanCacheVerify(t *testing.T) {
+	diskdb, hash, blob := newTestNodeDB()
+
+	db := NewDatabaseWithConfig(diskdb, &Config{Cache: 1, Verify: 1})
+	db.cleanCache().Set(hash[:], []byte{0xc2, 0x20, 0x02})
+
+	enc, err := db.Node(hash)
+	if err != nil {
+		t.Fatalf("failed to retrieve node: %v", err)
+	}
+	if !bytes.Equal(enc, blob) {
+		t.Fatalf("corrupted node served: have %x, want %x", enc, blob)
+	}
+	if cached := db.cleanCache().Get(nil, hash[:]); !bytes.Equal(cached, blob) {
+		t.Fatalf("clean cache not repaired: have %x, want %x", cached, blob)
+	}
+}
+
+// Tests that warming the database loads exactly the requested top levels of a
+// trie into the clean cache.
+func TestDatabaseWarm(t *testing.T) {
+	diskdb := memorydb.New()
+	trie, _ := New(common.Hash{}, NewDatabase(diskdb))
+	for i := 0; i < 1000; i++ {
+		key := crypto.Keccak256([]byte{byte(i), byte(i >> 8)})
+		trie.Update(key, key)
+	}
+	root, _ := trie.Commit(nil)
+	trie.db.Commit(root, false)
+
+	// Gather the first two levels of the trie straight from disk
+	blob, _ := diskdb.Get(root[:])
+	want := map[common.Hash]struct{}{root: {}}
+	forGatherChildren(simplifyNode(mustDecodeNode(root[:], blob)), func(child common.Hash) {
+		want[child] = struct{}{}
+	})
+	db := NewDatabaseWithCache(diskdb, 16)
+	if nodes := db.Warm([]common.Hash{root}, 2, 0, nil); nodes != len(want) {
+		t.Fatalf("warmed node count mismatch: have %d, want %d", nodes, len(want))
+	}
+	var (
+		cache = db.cleanCache().(*fastcache.Cache)
+		stats fastcache.Stats
+	)
+	cache.UpdateStats(&stats)
+	if int(stats.EntriesCount) != len(want) {
+		t.Fatalf("cached node count mismatch: have %d, want %d", stats.EntriesCount, len(want))
+	}
+	for hash := range want {
+		if !cache.Has(hash[:]) {
+			t.Errorf("node %x not warmed", hash)
+		}
+	}
+	// Warming with a budget must stop after loading that many nodes, preferring
+	// the shallowest ones
+	db = NewDatabaseWithCache(diskdb, 16)
+	if nodes := db.Warm([]common.Hash{root}, 2, 3, nil); nodes != 3 {
+		t.Fatalf("budgeted node count mismatch: have %d, want %d", nodes, 3)
+	}
+	if !db.cleanCache().(*fastcache.Cache).Has(root[:]) {
+		t.Errorf("root not warmed within budget")
+	}
+	// Aborted warming must not load anything
+	abort := make(chan struct{})
+	close(abort)
+
+	db = NewDatabaseWithCache(diskdb, 16)
+	if nodes := db.Warm([]common.Hash{root}, 2, 0, abort); nodes != 0 {
+		t.Fatalf("aborted warm-up loaded %d nodes", nodes)
+	}
+}
+
+// Tests that the clean cache can be persisted into a journal and loaded back on
+// the next database creation.
+func TestDatabaseCleanCacheJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trie-journal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "triecache")
+
+	diskdb, hash, blob := newTestNodeDB()
+
+	db := NewDatabaseWithConfig(diskdb, &Config{Cache: 1, Journal: journal})
+	if _, err := db.Node(hash); err != nil {
+		t.Fatalf("failed to retrieve node: %v", err)
+	}
+	if err := db.SaveCache(journal); err != nil {
+		t.Fatalf("failed to save clean cache: %v", err)
+	}
+	db = NewDatabaseWithConfig(memorydb.New(), &Config{Cache: 1, Journal: journal})
+	if enc, err := db.Node(hash); err != nil || !bytes.Equal(enc, blob) {
+		t.Fatalf("journaled node mismatch: have %x, want %x, err %v", enc, blob, err)
+	}
+	if db.verify != cleanJournalVerifyRate {
+		t.Fatalf("journaled cache verification mismatch: have %d, want %d", db.verify, cleanJournalVerifyRate)
+	}
+}
+
+// Tests that saving the clean cache with an empty journal path is a no-op and
+// never hands the cache a directory to persist itself into.
+func TestDatabaseCleanCacheNoJournal(t *testing.T) {
+	diskdb, hash, _ := newTestNodeDB()
+
+	cache := &journalCleanCache{testCleanCache: make(testCleanCache)}
+	db := NewDatabaseWithConfig(diskdb, &Config{CleanCache: cache})
+	if _, err := db.Node(hash); err != nil {
+		t.Fatalf("failed to retrieve node: %v", err)
+	}
+	if err := db.SaveCache(""); err != nil {
+		t.Fatalf("failed to save clean cache: %v", err)
+	}
+	if len(cache.saves) != 0 {
+		t.Fatalf("clean cache persisted without a journal: %v", cache.saves)
+	}
+}
+
+// testCleanCache is a map backed clean cache, used to test plugging external
+// cache implementations into the trie database.
+type testCleanCache map[string][]byte
+
+func (c testCleanCache) Get(dst, key []byte) []byte { return append(dst, c[string(key)]...) }
+func (c testCleanCache) Set(key, value []byte)      { c[string(key)] = common.CopyBytes(value) }
+func (c testCleanCache) Del(key []byte)             { delete(c, string(key)) }
+func (c testCleanCache) Reset() {
+	for key := range c {
+		delete(c, key)
+	}
+}
+func (c testCleanCache) SaveToFileConcurrent(dir string, concurrency int) error { return nil }
+
+// journalCleanCache is a testCleanCache recording every directory it's asked
+// to persist itself into.
+type journalCleanCache struct {
+	testCleanCache
+	saves []string
+}
+
+func (c *journalCleanCache) SaveToFileConcurrent(dir string, concurrency int) error {
+	c.saves = append(c.saves, dir)
+	return nil
+}
+
+// Tests that an externally provided clean cache is used instead of the default
+// one for storing and serving nodes loaded from disk.
+func TestDatabaseCustomCleanCache(t *testing.T) {
+	diskdb, hash, blob := newTestNodeDB()
+
+	cache := make(testCleanCache)
+	db := NewDatabaseWithConfig(diskdb, &Config{CleanCache: cache})
+	if _, err := db.Node(hash); err != nil {
+		t.Fatalf("failed to retrieve node: %v", err)
+	}
+	if !bytes.Equal(cache[string(hash[:])], blob) {
+		t.Fatalf("node not stored in custom cache")
+	}
+	diskdb.Delete(hash[:])
+	if enc, err := db.Node(hash); err != nil || !bytes.Equal(enc, blob) {
+		t.Fatalf("node not served from custom cache: have %x, err %v", enc, err)
+	}
+}
+
+// Tests that the clean cache can be resized at runtime, and that externally
+// provided caches are rejected.
+func TestDatabaseResizeCleanCache(t *testing.T) {
+	diskdb, hash, blob := newTestNodeDB()
+
+	db := NewDatabaseWithCache(diskdb, 0)
+	if err := db.ResizeCleanCache(1); err != nil {
+		t.Fatalf("failed to resize clean cache: %v", err)
+	}
+	db.Node(hash)
+	if cached := db.cleanCache().Get(nil, hash[:]); !bytes.Equal(cached, blob) {
+		t.Fatalf("node not cached after resize: have %x, want %x", cached, blob)
+	}
+	// Nodes cached before a resize must be served and moved into the new cache
+	// from the old one, even if the disk is unavailable
+	diskdb.Delete(hash[:])
+	if err := db.ResizeCleanCache(2); err != nil {
+		t.Fatalf("failed to resize clean cache: %v", err)
+	}
+	if enc, err := db.Node(hash); err != nil || !bytes.Equal(enc, blob) {
+		t.Fatalf("node not served from previous cache: have %x, err %v", enc, err)
+	}
+	if cached := db.cleanCache().Get(nil, hash[:]); !bytes.Equal(cached, blob) {
+		t.Fatalf("node not moved into resized cache: have %x, want %x", cached, blob)
+	}
+	// Once the new cache was filled, the old one must be released
+	for i := 0; i < 128; i++ {
+		db.cleanSet(common.BytesToHash([]byte{byte(i), 0x01}), make([]byte, 1024))
+	}
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		db.cleansLock.RLock()
+		prev := db.cleansPrev
+		db.cleansLock.RUnlock()
+
+		if prev == nil {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("previous clean cache not released after refill")
+		}
+	}
+	if err := db.ResizeCleanCache(0); err != nil {
+		t.Fatalf("failed to disable clean cache: %v", err)
+	}
+	if db.cleanCache() != nil {
+		t.Fatalf("clean cache not disabled")
+	}
+	db = NewDatabaseWithConfig(diskdb, &Config{CleanCache: make(testCleanCache)})
+	if err := db.ResizeCleanCache(1); err == nil {
+		t.Fatalf("external clean cache resized")
+	}
+}
+
+// Tests that resetting the clean cache drops every cached node right away, also
+// releasing the fallback of a previous resize still being refilled.
+func TestDatabaseResetCleanCache(t *testing.T) {
+	diskdb, hash, blob := newTestNodeDB()
+
+	db := NewDatabaseWithCache(diskdb, 1)
+	db.Node(hash)
+	if err := db.ResizeCleanCache(2); err != nil {
+		t.Fatalf("failed to resize clean cache: %v", err)
+	}
+	if db.cleansPrev == nil {
+		t.Fatalf("previous clean cache not kept after resize")
+	}
+	if err := db.ResetCleanCache(1); err != nil {
+		t.Fatalf("failed to reset clean cache: %v", err)
+	}
+	if db.cleansPrev != nil {
+		t.Fatalf("previous clean cache kept after reset")
+	}
+	if cached := db.cleanBlob(hash); cached != nil {
+		t.Fatalf("node cached after reset: have %x", cached)
+	}
+	if enc, err := db.Node(hash); err != nil || !bytes.Equal(enc, blob) {
+		t.Fatalf("node not reloaded after reset: have %x, err %v", enc, err)
+	}
+	db = NewDatabaseWithConfig(diskdb, &Config{CleanCache: make(testCleanCache)})
+	if err := db.ResetCleanCache(1); err == nil {
+		t.Fatalf("external clean cache reset")
+	}
+}
+
+// Tests that the clean cache can be resized concurrently with other resizes and
+// with nodes being read through it.
+func TestDatabaseResizeCleanCacheConcurrent(t *testing.T) {
+	diskdb, hash, blob := newTestNodeDB()
+
+	var (
+		db   = NewDatabaseWithCache(diskdb, 1)
+		pend sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		pend.Add(2)
+		go func(size int) {
+			defer pend.Done()
+			for j := 0; j < 16; j++ {
+				if err := db.ResizeCleanCache(size); err != nil {
+					t.Errorf("failed to resize clean cache: %v", err)
+				}
+			}
+		}(i % 2)
+		go func() {
+			defer pend.Done()
+			for j := 0; j < 256; j++ {
+				if enc, err := db.Node(hash); err != nil || !bytes.Equal(enc, blob) {
+					t.Errorf("node mismatch: have %x, want %x, err %v", enc, blob, err)
+					return
+				}
+			}
+		}()
+	}
+	pend.Wait()
+}
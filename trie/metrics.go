@@ -0,0 +1,174 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// MetricsSink is the destination a trie database reports its memory cache
+// statistics and a sync bloom its filter statistics into. It allows projects
+// embedding the trie package to route the measurements into their own metrics
+// system, or to drop them altogether, instead of writing into the global
+// go-ethereum registry.
+type MetricsSink interface {
+	// Meter retrieves (or creates) the meter with the given name.
+	Meter(name string) metrics.Meter
+
+	// Gauge retrieves (or creates) the gauge with the given name.
+	Gauge(name string) metrics.Gauge
+
+	// ResettingTimer retrieves (or creates) the resetting timer with the given name.
+	ResettingTimer(name string) metrics.ResettingTimer
+}
+
+// registrySink is a metrics sink backed by a go-ethereum metrics registry.
+type registrySink struct {
+	registry metrics.Registry
+}
+
+// NewRegistrySink creates a metrics sink that registers all trie database
+// metrics into the given registry. Metrics already registered under the same
+// name are reused, so multiple databases may share a single registry.
+func NewRegistrySink(registry metrics.Registry) MetricsSink {
+	return &registrySink{registry: registry}
+}
+
+// Meter implements MetricsSink, retrieving or registering a meter.
+func (s *registrySink) Meter(name string) metrics.Meter {
+	return metrics.GetOrRegisterMeter(name, s.registry)
+}
+
+// Gauge implements MetricsSink, retrieving or registering a gauge.
+func (s *registrySink) Gauge(name string) metrics.Gauge {
+	return metrics.GetOrRegisterGauge(name, s.registry)
+}
+
+// ResettingTimer implements MetricsSink, retrieving or registering a resetting
+// timer.
+func (s *registrySink) ResettingTimer(name string) metrics.ResettingTimer {
+	return metrics.GetOrRegisterResettingTimer(name, s.registry)
+}
+
+// noopSink is a metrics sink discarding all measurements.
+type noopSink struct{}
+
+// NoopMetrics is a metrics sink which discards everything reported into it.
+var NoopMetrics MetricsSink = noopSink{}
+
+// Meter implements MetricsSink, returning a no-op meter.
+func (noopSink) Meter(name string) metrics.Meter { return metrics.NilMeter{} }
+
+// Gauge implements MetricsSink, returning a no-op gauge.
+func (noopSink) Gauge(name string) metrics.Gauge { return metrics.NilGauge{} }
+
+// ResettingTimer implements MetricsSink, returning a no-op resetting timer.
+func (noopSink) ResettingTimer(name string) metrics.ResettingTimer {
+	return metrics.NilResettingTimer{}
+}
+
+// databaseMetrics is the set of meters and timers a trie database reports its
+// memory cache statistics into.
+type databaseMetrics struct {
+	cleanHit     metrics.Meter
+	cleanMiss    metrics.Meter
+	cleanRead    metrics.Meter
+	cleanWrite   metrics.Meter
+	cleanCorrupt metrics.Meter
+
+	dirtyHit   metrics.Meter
+	dirtyMiss  metrics.Meter
+	dirtyRead  metrics.Meter
+	dirtyWrite metrics.Meter
+
+	flushTime  metrics.ResettingTimer
+	flushNodes metrics.Meter
+	flushSize  metrics.Meter
+
+	gcTime  metrics.ResettingTimer
+	gcNodes metrics.Meter
+	gcSize  metrics.Meter
+
+	commitTime  metrics.ResettingTimer
+	commitNodes metrics.Meter
+	commitSize  metrics.Meter
+
+	proofHit  metrics.Meter
+	proofMiss metrics.Meter
+}
+
+// newDatabaseMetrics creates all the trie database metrics in the given sink.
+// If no sink is specified, the global go-ethereum registry is used.
+func newDatabaseMetrics(sink MetricsSink) *databaseMetrics {
+	if sink == nil {
+		sink = NewRegistrySink(metrics.DefaultRegistry)
+	}
+	return &databaseMetrics{
+		cleanHit:     sink.Meter("trie/memcache/clean/hit"),
+		cleanMiss:    sink.Meter("trie/memcache/clean/miss"),
+		cleanRead:    sink.Meter("trie/memcache/clean/read"),
+		cleanWrite:   sink.Meter("trie/memcache/clean/write"),
+		cleanCorrupt: sink.Meter("trie/memcache/clean/corrupt"),
+
+		dirtyHit:   sink.Meter("trie/memcache/dirty/hit"),
+		dirtyMiss:  sink.Meter("trie/memcache/dirty/miss"),
+		dirtyRead:  sink.Meter("trie/memcache/dirty/read"),
+		dirtyWrite: sink.Meter("trie/memcache/dirty/write"),
+
+		flushTime:  sink.ResettingTimer("trie/memcache/flush/time"),
+		flushNodes: sink.Meter("trie/memcache/flush/nodes"),
+		flushSize:  sink.Meter("trie/memcache/flush/size"),
+
+		gcTime:  sink.ResettingTimer("trie/memcache/gc/time"),
+		gcNodes: sink.Meter("trie/memcache/gc/nodes"),
+		gcSize:  sink.Meter("trie/memcache/gc/size"),
+
+		commitTime:  sink.ResettingTimer("trie/memcache/commit/time"),
+		commitNodes: sink.Meter("trie/memcache/commit/nodes"),
+		commitSize:  sink.Meter("trie/memcache/commit/size"),
+
+		proofHit:  sink.Meter("trie/memcache/proof/hit"),
+		proofMiss: sink.Meter("trie/memcache/proof/miss"),
+	}
+}
+
+// syncBloomMetrics is the set of meters and gauges a sync bloom reports its
+// filter statistics into.
+type syncBloomMetrics struct {
+	add       metrics.Meter
+	load      metrics.Meter
+	test      metrics.Meter
+	miss      metrics.Meter
+	fault     metrics.Meter
+	errorRate metrics.Gauge
+}
+
+// newSyncBloomMetrics creates all the sync bloom metrics in the given sink. If
+// no sink is specified, the global go-ethereum registry is used.
+func newSyncBloomMetrics(sink MetricsSink) *syncBloomMetrics {
+	if sink == nil {
+		sink = NewRegistrySink(metrics.DefaultRegistry)
+	}
+	return &syncBloomMetrics{
+		add:       sink.Meter("trie/bloom/add"),
+		load:      sink.Meter("trie/bloom/load"),
+		test:      sink.Meter("trie/bloom/test"),
+		miss:      sink.Meter("trie/bloom/miss"),
+		fault:     sink.Meter("trie/bloom/fault"),
+		errorRate: sink.Gauge("trie/bloom/error"),
+	}
+}
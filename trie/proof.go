@@ -36,6 +36,16 @@ import (
 // nodes of the longest existing prefix of the key (at least the root node), ending
 // with the node that proves the absence of the key.
 func (t *Trie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	// If the trie is already hashed, the proof may be served from the cache
+	cacheKey, cacheable := t.proofCacheKey(key)
+	if cacheable {
+		if proof, ok := t.db.proofs.Get(cacheKey); ok {
+			t.db.metrics.proofHit.Mark(1)
+			writeProof(proof.([]proofNode), fromLevel, proofDb)
+			return nil
+		}
+		t.db.metrics.proofMiss.Mark(1)
+	}
 	// Collect all nodes on the path to key.
 	key = keybytesToHex(key)
 	var nodes []node
@@ -69,9 +79,11 @@ func (t *Trie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) e
 	hasher := newHasher(false)
 	defer returnHasherToPool(hasher)
 
+	// Encode the proof elements. If the proof is going to be cached, all levels
+	// are needed, otherwise the ones below the requested level can be skipped.
+	proof := make([]proofNode, len(nodes))
 	for i, n := range nodes {
-		if fromLevel > 0 {
-			fromLevel--
+		if !cacheable && uint(i) < fromLevel {
 			continue
 		}
 		var hn node
@@ -83,12 +95,50 @@ func (t *Trie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) e
 			if !ok {
 				hash = hasher.hashData(enc)
 			}
-			proofDb.Put(hash, enc)
+			proof[i] = proofNode{hash: hash, blob: enc}
 		}
 	}
+	if cacheable {
+		t.db.proofs.Add(cacheKey, proof)
+	}
+	writeProof(proof, fromLevel, proofDb)
 	return nil
 }
 
+// proofNode is a single element of a cached merkle proof. Nodes embedded into
+// their parents are not proof elements on their own and have no hash set.
+type proofNode struct {
+	hash []byte
+	blob []byte
+}
+
+// proofCacheKey returns the key under which the proof of the given trie key is
+// cached in the database, and whether the proof can be cached at all. Only tries
+// with a known root hash are cacheable, since for those the proof is immutable.
+func (t *Trie) proofCacheKey(key []byte) (string, bool) {
+	if t.db == nil || t.db.proofs == nil || t.root == nil {
+		return "", false
+	}
+	root, ok := t.root.(hashNode)
+	if !ok {
+		if root, _ = t.root.cache(); root == nil {
+			return "", false
+		}
+	}
+	return string(root) + string(key), true
+}
+
+// writeProof inserts the elements of a proof from the given level onward into
+// the proof database.
+func writeProof(proof []proofNode, fromLevel uint, proofDb ethdb.KeyValueWriter) {
+	for i, n := range proof {
+		if uint(i) < fromLevel || n.hash == nil {
+			continue
+		}
+		proofDb.Put(n.hash, n.blob)
+	}
+}
+
 // Prove constructs a merkle proof for key. The result contains all encoded nodes
 // on the path to the value at key. The value itself is also included in the last
 // node and can be retrieved by verifying the proof.
@@ -77,6 +77,44 @@ func TestProof(t *testing.T) {
 	}
 }
 
+// Tests that proofs served from the database's proof cache are identical to the
+// freshly generated ones, also when skipping some of the top levels.
+func TestCachedProof(t *testing.T) {
+	src, vals := randomTrie(500)
+
+	db := NewDatabaseWithConfig(memorydb.New(), &Config{Proofs: 1024})
+	trie, _ := New(common.Hash{}, db)
+	for _, kv := range vals {
+		trie.Update(kv.k, kv.v)
+	}
+	root, err := trie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	trie, _ = New(root, db)
+
+	for _, kv := range vals {
+		// Request every proof twice to exercise both the miss and the hit path
+		for _, level := range []uint{0, 2, 0, 2} {
+			want, have := memorydb.New(), memorydb.New()
+			src.Prove(kv.k, level, want)
+			if err := trie.Prove(kv.k, level, have); err != nil {
+				t.Fatalf("failed to prove key %x: %v", kv.k, err)
+			}
+			if want.Len() != have.Len() {
+				t.Fatalf("proof size mismatch for key %x level %d: have %d, want %d", kv.k, level, have.Len(), want.Len())
+			}
+			it := want.NewIterator(nil, nil)
+			for it.Next() {
+				if blob, _ := have.Get(it.Key()); !bytes.Equal(blob, it.Value()) {
+					t.Fatalf("proof element %x mismatch for key %x level %d", it.Key(), kv.k, level)
+				}
+			}
+			it.Release()
+		}
+	}
+}
+
 func TestOneElementProof(t *testing.T) {
 	trie := new(Trie)
 	updateString(trie, "k", "v")
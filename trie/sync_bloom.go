@@ -27,19 +27,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/steakknife/bloomfilter"
 )
 
-var (
-	bloomAddMeter   = metrics.NewRegisteredMeter("trie/bloom/add", nil)
-	bloomLoadMeter  = metrics.NewRegisteredMeter("trie/bloom/load", nil)
-	bloomTestMeter  = metrics.NewRegisteredMeter("trie/bloom/test", nil)
-	bloomMissMeter  = metrics.NewRegisteredMeter("trie/bloom/miss", nil)
-	bloomFaultMeter = metrics.NewRegisteredMeter("trie/bloom/fault", nil)
-	bloomErrorGauge = metrics.NewRegisteredGauge("trie/bloom/error", nil)
-)
-
 // syncBloomHasher is a wrapper around a byte blob to satisfy the interface API
 // requirements of the bloom library used. It's used to convert a trie hash into
 // a 64 bit mini hash.
@@ -62,11 +52,15 @@ type SyncBloom struct {
 	closer sync.Once
 	closed uint32
 	pend   sync.WaitGroup
+
+	metrics *syncBloomMetrics // Filter statistics reported into the metrics sink
 }
 
 // NewSyncBloom creates a new bloom filter of the given size (in megabytes) and
 // initializes it from the database. The bloom is hard coded to use 3 filters.
-func NewSyncBloom(memory uint64, database ethdb.Iteratee) *SyncBloom {
+// Its statistics are reported into the given metrics sink, or into the global
+// go-ethereum registry if none is specified.
+func NewSyncBloom(memory uint64, database ethdb.Iteratee, sink MetricsSink) *SyncBloom {
 	// Create the bloom filter to track known trie nodes
 	bloom, err := bloomfilter.New(memory*1024*1024*8, 3)
 	if err != nil {
@@ -76,7 +70,8 @@ func NewSyncBloom(memory uint64, database ethdb.Iteratee) *SyncBloom {
 
 	// Assemble the fast sync bloom and init it from previous sessions
 	b := &SyncBloom{
-		bloom: bloom,
+		bloom:   bloom,
+		metrics: newSyncBloomMetrics(sink),
 	}
 	b.pend.Add(2)
 	go func() {
@@ -109,7 +104,7 @@ func (b *SyncBloom) init(database ethdb.Iteratee) {
 		// If the database entry is a trie node, add it to the bloom
 		if key := it.Key(); len(key) == common.HashLength {
 			b.bloom.Add(syncBloomHasher(key))
-			bloomLoadMeter.Mark(1)
+			b.metrics.load.Mark(1)
 		}
 		// If enough time elapsed since the last iterator swap, restart
 		if time.Since(swap) > 8*time.Second {
@@ -134,7 +129,7 @@ func (b *SyncBloom) init(database ethdb.Iteratee) {
 func (b *SyncBloom) meter() {
 	for {
 		// Report the current error ration. No floats, lame, scale it up.
-		bloomErrorGauge.Update(int64(b.errorRate() * 100000))
+		b.metrics.errorRate.Update(int64(b.errorRate() * 100000))
 
 		// Wait one second, but check termination more frequently
 		for i := 0; i < 10; i++ {
@@ -169,7 +164,7 @@ func (b *SyncBloom) Add(hash []byte) {
 		return
 	}
 	b.bloom.Add(syncBloomHasher(hash))
-	bloomAddMeter.Mark(1)
+	b.metrics.add.Mark(1)
 }
 
 // Contains tests if the bloom filter contains the given hash:
@@ -178,7 +173,7 @@ func (b *SyncBloom) Add(hash []byte) {
 //
 // While the bloom is being initialized, any query will return true.
 func (b *SyncBloom) Contains(hash []byte) bool {
-	bloomTestMeter.Mark(1)
+	b.metrics.test.Mark(1)
 	if atomic.LoadUint32(&b.inited) == 0 {
 		// We didn't load all the trie nodes from the previous run of Geth yet. As
 		// such, we can't say for sure if a hash is not present for anything. Until
@@ -188,7 +183,7 @@ func (b *SyncBloom) Contains(hash []byte) bool {
 	// Bloom initialized, check the real one and report any successful misses
 	maybe := b.bloom.Contains(syncBloomHasher(hash))
 	if !maybe {
-		bloomMissMeter.Mark(1)
+		b.metrics.miss.Mark(1)
 	}
 	return maybe
 }
@@ -82,6 +82,19 @@ func New(root common.Hash, db *Database) (*Trie, error) {
 	return trie, nil
 }
 
+// Copy returns a copy of the trie. Since trie nodes are never modified in place,
+// the copy shares all nodes with the original and only diverges on updates, so
+// copying is cheap regardless of the trie size. The original and its copies can
+// be read and updated from different goroutines, but committing them into the
+// shared database is subject to the database's concurrency constraints.
+func (t *Trie) Copy() *Trie {
+	return &Trie{
+		db:       t.db,
+		root:     t.root,
+		unhashed: t.unhashed,
+	}
+}
+
 // NodeIterator returns an iterator that returns nodes of the trie. Iteration starts at
 // the key after the given start key.
 func (t *Trie) NodeIterator(start []byte) NodeIterator {
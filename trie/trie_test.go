@@ -25,6 +25,7 @@ import (
 	"math/rand"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"testing/quick"
 
@@ -854,3 +855,47 @@ func TestDecodeNode(t *testing.T) {
 		decodeNode(hash, elems)
 	}
 }
+
+// Tests that copies of a trie share their nodes with the original, but can be
+// updated concurrently without affecting each other.
+func TestTrieCopy(t *testing.T) {
+	orig := newEmpty()
+	for i := 0; i < 256; i++ {
+		orig.Update(crypto.Keccak256([]byte{byte(i)}), []byte{byte(i)})
+	}
+	root := orig.Hash()
+
+	var (
+		wg     sync.WaitGroup
+		copies = make([]*Trie, 8)
+		hashes = make([]common.Hash, 8)
+	)
+	for i := range copies {
+		copies[i] = orig.Copy()
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 64; j++ {
+				key := crypto.Keccak256([]byte{byte(j)})
+				copies[i].Get(key)
+				copies[i].Update(key, []byte{byte(i), byte(j)})
+			}
+			hashes[i] = copies[i].Hash()
+		}(i)
+	}
+	wg.Wait()
+
+	if have := orig.Hash(); have != root {
+		t.Fatalf("original trie modified by copies: have %x, want %x", have, root)
+	}
+	for i := range copies {
+		want := orig.Copy()
+		for j := 0; j < 64; j++ {
+			want.Update(crypto.Keccak256([]byte{byte(j)}), []byte{byte(i), byte(j)})
+		}
+		if hashes[i] != want.Hash() {
+			t.Errorf("copy %d: root mismatch: have %x, want %x", i, hashes[i], want.Hash())
+		}
+	}
+}